@@ -0,0 +1,252 @@
+package main
+
+import (
+	"container/vector"
+	"fmt"
+	"go/ast"
+	"os"
+	"strings"
+	"github.com/droundy/go-crazy/transform"
+)
+
+// loopCost is the extra cost charged for every loop in a function
+// body, since duplicating a loop at each call site rarely pays off.
+const loopCost = 10
+
+// builtins are the predeclared functions and types that may be called
+// without making a function a non-leaf.
+var builtins = map[string]bool{
+	"cap": true, "close": true, "closed": true, "cmplx": true,
+	"copy": true, "imag": true, "len": true, "make": true,
+	"new": true, "panic": true, "panicln": true, "print": true,
+	"println": true, "real": true, "recover": true,
+
+	"bool": true, "byte": true, "complex": true, "complex64": true,
+	"complex128": true, "float": true, "float32": true, "float64": true,
+	"int": true, "int8": true, "int16": true, "int32": true, "int64": true,
+	"string": true, "uint": true, "uint8": true, "uint16": true,
+	"uint32": true, "uint64": true, "uintptr": true,
+}
+
+// An InlineDecision records whether InlineAuto chose to inline a
+// function or method, and why.
+type InlineDecision struct {
+	Decl   *ast.FuncDecl
+	Pkg    string // the package name, for a function from an Export
+	Cost   int
+	Inline bool
+	Reason string
+}
+
+// Name returns the name of the function, qualified by its receiver
+// type if it is a method, or by its package if it is imported.
+func (d *InlineDecision) Name() string { return funcName(d.Decl, d.Pkg) }
+
+func (d *InlineDecision) String() string {
+	if d.Inline {
+		return fmt.Sprintf("%s: inlining %s: %s", d.Decl.Pos(), d.Name(), d.Reason)
+	}
+	return fmt.Sprintf("%s: not inlining %s: %s", d.Decl.Pos(), d.Name(), d.Reason)
+}
+
+// InlineAuto inlines every small leaf function and operator method
+// declared in pkg or recorded in st.Exports whose estimated cost is at
+// most budget.  It returns the modified package together with a
+// decision for every function it considered, and any errors found
+// while inlining.  Unlike Inline, it
+// leaves the declarations in place, since they may still be
+// referenced other than by calls.  Calls to the functions it rejects
+// are recorded in st.Report too.
+//
+// A function that calls nothing but itself is inlined to depth
+// levels if its cost, multiplied by depth, is within budget.
+func InlineAuto(pkg *ast.Package, budget, depth int, st *PassState) (*ast.Package, []*InlineDecision, os.Error) {
+	types := make(map[string]bool)
+	methods := make(map[string]int)
+	var decisions vector.Vector
+	inliner := NewInliner(st)
+	inliner.depth = depth
+	graph := newCallGraph(pkg, st.Exports)
+	for _, f := range pkg.Files {
+		for _, d := range f.Decls {
+			switch d := d.(type) {
+			case *ast.GenDecl:
+				for _, s := range d.Specs {
+					if ts, ok := s.(*ast.TypeSpec); ok {
+						types[ts.Name.Name] = true
+					}
+				}
+			case *ast.FuncDecl:
+				if d.Recv != nil {
+					methods[d.Name.Name]++
+				}
+			}
+		}
+	}
+	for _, f := range pkg.Files {
+		for _, d := range f.Decls {
+			if d, ok := d.(*ast.FuncDecl); ok {
+				decision := decideInline(d, types, methods, budget, depth)
+				inliner.recursive[d] = graph.recursive(d)
+				if d.Recv == nil || methods[d.Name.Name] == 1 {
+					inliner.AddFunc(d, f)
+					if inliner.recursive[d] {
+						inliner.Preserve(d)
+					}
+					if !decision.Inline {
+						inliner.Reject(d, decision.Reason)
+					}
+				}
+				decisions.Push(decision)
+			}
+		}
+	}
+	for _, exp := range st.Exports {
+		for _, d := range exp.File.Decls {
+			if d, ok := d.(*ast.FuncDecl); ok {
+				decision := decideInline(d, make(map[string]bool), methods, budget, depth)
+				inliner.recursive[d] = graph.recursive(d)
+				decision.Pkg = exp.Name()
+				inliner.AddImported(exp, d)
+				if !decision.Inline {
+					inliner.Reject(d, decision.Reason)
+				}
+				decisions.Push(decision)
+			}
+		}
+	}
+
+	list := make([]*InlineDecision, len(decisions))
+	for i, x := range decisions {
+		list[i] = x.(*InlineDecision)
+	}
+	pkg, err := inlinePackage(inliner, pkg)
+	return pkg, list, err
+}
+
+func decideInline(decl *ast.FuncDecl, types map[string]bool, methods map[string]int, budget, depth int) *InlineDecision {
+	d := &InlineDecision{Decl: decl}
+	if decl.Body == nil {
+		d.Reason = "no body"
+		return d
+	}
+	est := costEstimator{decl: decl, types: types}
+	if _, err := transform.TryWalk(&est, decl.Body); err != nil {
+		d.Reason = err.String()
+		return d
+	}
+	d.Cost = est.nodes + loopCost*est.loops
+
+	name := decl.Name.Name
+	switch {
+	case decl.Recv == nil && (name == "main" || name == "init"):
+		d.Reason = "called by the runtime"
+	case decl.Recv != nil && !isOperatorMethod(name):
+		d.Reason = "not an operator method"
+	case decl.Recv != nil && isPointer(decl.Recv.List[0].Type):
+		d.Reason = "pointer receiver"
+	case decl.Recv != nil && methods[name] > 1:
+		d.Reason = fmt.Sprintf("%s is declared for %d types", name, methods[name])
+	case est.recursive && depth == 0:
+		d.Reason = "recursive"
+	case est.defers > 0:
+		d.Reason = "contains defer"
+	case est.recovers > 0:
+		d.Reason = "calls recover"
+	case est.callee != "":
+		d.Reason = "not a leaf: calls " + est.callee
+	case est.recursive && d.Cost*depth > budget:
+		d.Reason = fmt.Sprintf("cost %d at depth %d exceeds budget %d", d.Cost, depth, budget)
+	case d.Cost > budget:
+		d.Reason = fmt.Sprintf("cost %d exceeds budget %d", d.Cost, budget)
+	default:
+		d.Inline = true
+		d.Reason = fmt.Sprintf("cost %d within budget %d", d.Cost, budget)
+	}
+	return d
+}
+
+// A costEstimator walks a function body, counting its nodes and
+// noting the constructs that make it a poor candidate for inlining.
+type costEstimator struct {
+	decl      *ast.FuncDecl
+	types     map[string]bool // types declared in the file
+	nodes     int
+	loops     int
+	defers    int
+	recovers  int
+	recursive bool
+	callee    string // the first function called, other than itself
+}
+
+func (v *costEstimator) Visit(node interface{}) interface{} {
+	if _, ok := node.(ast.Node); ok {
+		v.nodes++
+	}
+	switch n := node.(type) {
+	case *ast.ForStmt, *ast.RangeStmt:
+		v.loops++
+	case *ast.DeferStmt:
+		v.defers++
+	case *ast.CallExpr:
+		v.call(n)
+	}
+	return nil
+}
+
+func (v *costEstimator) call(n *ast.CallExpr) {
+	switch f := n.Fun.(type) {
+	case *ast.Ident:
+		if f.Name == "recover" {
+			v.recovers++
+		}
+		if v.decl.Recv == nil && f.Name == v.decl.Name.Name {
+			v.recursive = true
+			return
+		}
+		if builtins[f.Name] || v.types[f.Name] {
+			return
+		}
+	case *ast.SelectorExpr:
+		if v.decl.Recv != nil && f.Sel.Name == v.decl.Name.Name {
+			v.recursive = true
+			return
+		}
+	case *ast.ArrayType, *ast.MapType, *ast.ChanType, *ast.FuncType, *ast.InterfaceType:
+		return // a conversion
+	}
+	if v.callee == "" {
+		v.callee = exprString(n.Fun)
+	}
+}
+
+func isOperatorMethod(name string) bool {
+	return strings.HasPrefix(name, "_dot_") || name == "_mul_dot"
+}
+
+func isPointer(x ast.Expr) bool {
+	_, ok := x.(*ast.StarExpr)
+	return ok
+}
+
+func derefType(x ast.Expr) ast.Expr {
+	if p, ok := x.(*ast.StarExpr); ok {
+		return p.X
+	}
+	return x
+}
+
+// exprString returns a short description of x for use in messages.
+func exprString(x ast.Expr) string {
+	switch x := x.(type) {
+	case *ast.Ident:
+		return x.Name
+	case *ast.SelectorExpr:
+		return exprString(x.X) + "." + x.Sel.Name
+	case *ast.StarExpr:
+		return "*" + exprString(x.X)
+	case *ast.ParenExpr:
+		return "(" + exprString(x.X) + ")"
+	}
+	return "a function value"
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"os"
+	"strings"
+	"github.com/droundy/go-crazy/parser"
+	"github.com/droundy/go-crazy/scanner"
+	"github.com/droundy/go-crazy/transform"
+)
+
+// An OperatorBinding makes a dotted operator on a type that can't be
+// given operator methods, such as one of another package, call one of
+// the methods it has.
+type OperatorBinding struct {
+	Type   ast.Expr // the type of the receiver of the operator
+	Op     string   // the operator method bound, such as _dot_add
+	Method string   // the method it calls
+	Dest   bool     // whether Method is called as dest.Method(left, right)
+}
+
+// ParseBinding parses a binding written as "TYPE OP METHOD", followed
+// by its calling convention: "recv", the default, to call METHOD of
+// the receiver of the operator, as in x.Plus(y), or "dest" to call
+// METHOD of a new value of TYPE, which must be a pointer type, given
+// both operands, as in new(big.Int).Add(x, y).  An in-place operator
+// bound by "dest" gives its receiver as the destination, as in
+// x.Add(x, y).
+func ParseBinding(s string) (*OperatorBinding, os.Error) {
+	fields := strings.Fields(s)
+	if len(fields) < 3 || len(fields) > 4 {
+		return nil, os.NewError("expected 'TYPE OP METHOD [recv|dest]': " + s)
+	}
+	typ, err := parser.ParseExpr("", fields[0])
+	if err != nil {
+		return nil, err
+	}
+	b := &OperatorBinding{typ, operatorMethod(fields[1]), fields[2], false}
+	if b.Op == "" {
+		return nil, os.NewError("not a dotted operator: " + fields[1])
+	}
+	if len(fields) == 4 {
+		switch fields[3] {
+		case "recv":
+		case "dest":
+			if _, ok := typ.(*ast.StarExpr); !ok {
+				return nil, os.NewError("the dest convention needs a pointer type: " + s)
+			}
+			b.Dest = true
+		default:
+			return nil, os.NewError("unknown calling convention: " + fields[3])
+		}
+	}
+	return b, nil
+}
+
+// BindOperators replaces each dotted operator in pkg whose receiver is
+// of a type bound by bindings with a call of the method it is bound
+// to.  Types are found by transform.InferTypes, and the result of a
+// bound operator is taken to be of the type bound, so that operators
+// on the results of others are bound as well.  A "dest" binding
+// evaluates the receiver of an in-place operator twice, and so
+// reports one that may have side effects as an error.  The positions
+// of the code generated are recorded in st.Origins.
+func BindOperators(pkg *ast.Package, bindings []*OperatorBinding, st *PassState) (*ast.Package, os.Error) {
+	errs := new(transform.Errors)
+	types := transform.InferTypes(pkg)
+	post := func(c *transform.Cursor) bool {
+		call, ok := c.Node().(*ast.CallExpr)
+		if !ok || len(call.Args) != 1 {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || !isOperatorMethod(sel.Sel.Name) {
+			return true
+		}
+		if b := findBinding(bindings, types.TypeOf(sel.X), sel.Sel.Name); b != nil {
+			if b.Dest && strings.HasSuffix(b.Op, "_assign") && !isPure(sel.X) {
+				errs.Errorf(sel.Sel.Pos(), "cannot bind %s to %s: its receiver would be evaluated twice", operatorName(b.Op), b.Method)
+				return true
+			}
+			x := b.call(sel.X, call.Args[0])
+			transform.Synthesize(x, call, st.Origins)
+			types.SetType(call, b.Type)
+			types.SetType(x, b.Type)
+			c.Replace(x)
+		}
+		return true
+	}
+	pkg = transform.Apply(pkg, nil, post).(*ast.Package)
+	return pkg, errs.GetError(scanner.Sorted)
+}
+
+// findBinding returns the binding among bindings of the operator
+// method op on typ, or nil if there is none.
+func findBinding(bindings []*OperatorBinding, typ ast.Expr, op string) *OperatorBinding {
+	if typ == nil {
+		return nil
+	}
+	for _, b := range bindings {
+		if b.Op == op && transform.Equal(b.Type, typ, 0) {
+			return b
+		}
+	}
+	return nil
+}
+
+// call returns the call of the method bound to the operator with
+// receiver recv and argument arg.
+func (b *OperatorBinding) call(recv, arg ast.Expr) *ast.CallExpr {
+	var nopos token.Position
+	method := func(x ast.Expr, args ...ast.Expr) *ast.CallExpr {
+		return &ast.CallExpr{&ast.SelectorExpr{x, ast.NewIdent(b.Method)}, nopos, args, nopos, nopos}
+	}
+	if !b.Dest {
+		return method(recv, arg)
+	}
+	if strings.HasSuffix(b.Op, "_assign") {
+		return method(transform.Copy(recv).(ast.Expr), recv, arg)
+	}
+	left, right := recv, arg
+	if b.Op == "_mul_dot" {
+		left, right = arg, recv
+	}
+	dest := builtinCall("new", transform.Copy(b.Type.(*ast.StarExpr).X).(ast.Expr))
+	return method(dest, left, right)
+}
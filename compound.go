@@ -0,0 +1,165 @@
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"os"
+	"strings"
+	"github.com/droundy/go-crazy/scanner"
+	"github.com/droundy/go-crazy/transform"
+)
+
+// operatorCall returns x and its selector if x is a call of an
+// operator method, or ok false if it is not.
+func operatorCall(x ast.Expr) (call *ast.CallExpr, sel *ast.SelectorExpr, ok bool) {
+	call, ok = x.(*ast.CallExpr)
+	if !ok || len(call.Args) != 1 {
+		return nil, nil, false
+	}
+	sel, ok = call.Fun.(*ast.SelectorExpr)
+	if !ok || !isOperatorMethod(sel.Sel.Name) {
+		return nil, nil, false
+	}
+	return call, sel, true
+}
+
+// CompoundOperators supplies the dotted operators that a type lacks
+// from the others.  A dotted assignment such as a .+= b, on a type
+// with no method for it, becomes a = a .+ b, and so is reported as an
+// error if evaluating a may have side effects.
+// A dotted operator such as a .+ b, on a type with a method for .+=
+// but none for .+, applies .+= to a copy of a, so that the in-place
+// method is used wherever it is available.  Methods bound with
+// --bind-operator count as declared.  Types are found by
+// transform.InferTypes, and operators whose receiver types it can't
+// find are left alone.  The positions of the code generated are
+// recorded in st.Origins.
+func CompoundOperators(pkg *ast.Package, st *PassState) (*ast.Package, os.Error) {
+	errs := new(transform.Errors)
+	types := transform.InferTypes(pkg)
+	declared := func(typ ast.Expr, name string) bool {
+		return types.MethodType(typ, name) != nil || findBinding(st.Bindings, typ, name) != nil
+	}
+	post := func(c *transform.Cursor) bool {
+		switch n := c.Node().(type) {
+		case *ast.ExprStmt:
+			call, sel, ok := operatorCall(n.X)
+			if !ok || !strings.HasSuffix(sel.Sel.Name, "_assign") {
+				return true
+			}
+			typ := types.TypeOf(sel.X)
+			if typ == nil || declared(typ, sel.Sel.Name) {
+				return true
+			}
+			name := sel.Sel.Name[0 : len(sel.Sel.Name)-len("_assign")]
+			if !isPure(sel.X) {
+				errs.Errorf(sel.Sel.Pos(), "cannot make %s of %s: its left operand would be evaluated twice", operatorName(sel.Sel.Name), operatorName(name))
+				return true
+			}
+			value := &ast.CallExpr{&ast.SelectorExpr{transform.Copy(sel.X).(ast.Expr), &ast.Ident{sel.Sel.Pos(), name, nil}},
+				call.Lparen, call.Args, call.Ellipsis, call.Rparen}
+			x := &ast.AssignStmt{[]ast.Expr{sel.X}, sel.Sel.Pos(), token.ASSIGN, []ast.Expr{value}}
+			transform.Synthesize(x, n, st.Origins)
+			c.Replace(x)
+
+		case *ast.CallExpr:
+			call, sel, ok := operatorCall(n)
+			if !ok || strings.HasSuffix(sel.Sel.Name, "_assign") {
+				return true
+			}
+			typ := types.TypeOf(sel.X)
+			if typ == nil || declared(typ, sel.Sel.Name) || !declared(typ, sel.Sel.Name+"_assign") {
+				return true
+			}
+			x := inPlace(types, typ, call)
+			if x == nil {
+				errs.Errorf(sel.Sel.Pos(), "cannot apply %s= to a copy of a %s", operatorName(sel.Sel.Name), typeString(typ))
+				return true
+			}
+			transform.Synthesize(x, call, st.Origins)
+			types.SetType(call, typ)
+			types.SetType(x, typ)
+			c.Replace(x)
+		}
+		return true
+	}
+	pkg = transform.Apply(pkg, nil, post).(*ast.Package)
+	return pkg, errs.GetError(scanner.Sorted)
+}
+
+// inPlace returns the dotted operator call of a receiver of type typ
+// applying the in-place form of the operator to a copy of its
+// receiver, or nil if a value of type typ can't be copied.
+//
+//	func(a typ) typ {
+//		c := make(typ, len(a)) // or c := a, if typ is not a slice
+//		copy(c, a)
+//		c._dot_add_assign(b)
+//		return c
+//	}(x)
+//
+func inPlace(types *transform.Types, typ ast.Expr, call *ast.CallExpr) ast.Expr {
+	sel := call.Fun.(*ast.SelectorExpr)
+	g := transform.NewGensym(typ, call)
+	a, c := g.Name("a"), g.Name("c")
+	id := ast.NewIdent
+	var nopos token.Position
+	var body []ast.Stmt
+	switch u := types.Underlying(typ).(type) {
+	case *ast.StarExpr, *ast.MapType, *ast.ChanType, *ast.FuncType, *ast.InterfaceType:
+		// copying these would share what they refer to
+		return nil
+	case *ast.ArrayType:
+		if u.Len == nil {
+			body = appendStmts(body,
+				&ast.AssignStmt{[]ast.Expr{id(c)}, nopos, token.DEFINE, []ast.Expr{
+					builtinCall("make", transform.Copy(typ).(ast.Expr), builtinCall("len", id(a))),
+				}},
+				&ast.ExprStmt{builtinCall("copy", id(c), id(a))})
+		}
+	}
+	if body == nil {
+		body = appendStmts(body, &ast.AssignStmt{[]ast.Expr{id(c)}, nopos, token.DEFINE, []ast.Expr{id(a)}})
+	}
+	apply := &ast.CallExpr{&ast.SelectorExpr{id(c), id(sel.Sel.Name + "_assign")}, nopos, call.Args, nopos, nopos}
+	body = appendStmts(body, &ast.ExprStmt{apply}, &ast.ReturnStmt{nopos, []ast.Expr{id(c)}})
+	params := &ast.FieldList{nopos, []*ast.Field{&ast.Field{nil, []*ast.Ident{id(a)}, transform.Copy(typ).(ast.Expr), nil, nil}}, nopos}
+	results := &ast.FieldList{nopos, []*ast.Field{&ast.Field{nil, nil, transform.Copy(typ).(ast.Expr), nil, nil}}, nopos}
+	fn := &ast.FuncLit{&ast.FuncType{nopos, params, results}, &ast.BlockStmt{nopos, body, nopos}}
+	return &ast.CallExpr{fn, nopos, []ast.Expr{sel.X}, nopos, nopos}
+}
+
+// isPure returns true if evaluating x can have no side effects, other
+// than a run-time panic.
+func isPure(x ast.Expr) bool {
+	switch x := x.(type) {
+	case *ast.Ident, *ast.BasicLit:
+		return true
+	case *ast.ParenExpr:
+		return isPure(x.X)
+	case *ast.SelectorExpr:
+		return isPure(x.X)
+	case *ast.IndexExpr:
+		return isPure(x.X) && isPure(x.Index)
+	case *ast.StarExpr:
+		return isPure(x.X)
+	case *ast.UnaryExpr:
+		return x.Op != token.ARROW && isPure(x.X)
+	case *ast.BinaryExpr:
+		return isPure(x.X) && isPure(x.Y)
+	case *ast.CompositeLit:
+		for _, e := range x.Elts {
+			if kv, ok := e.(*ast.KeyValueExpr); ok {
+				if _, ok := kv.Key.(*ast.Ident); !ok && !isPure(kv.Key) {
+					return false
+				}
+				e = kv.Value
+			}
+			if !isPure(e) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"container/vector"
+	"go/ast"
+	"go/printer"
+	"go/token"
+	"io"
+	"os"
+	"github.com/droundy/go-crazy/parser"
+	"github.com/droundy/go-crazy/transform"
+)
+
+// An Export holds the bodies of the functions that a package makes
+// available for inlining into the packages that import it.  It is
+// stored as a Go source file holding the package clause, the imports
+// used by the bodies, and the function declarations themselves, in
+// which every reference to the package's own top-level names is
+// qualified by the package name.
+type Export struct {
+	Path string // the import path of the package
+	File *ast.File
+}
+
+// ReadExport reads the export file filename, which records the
+// package imported as path.
+func ReadExport(path, filename string) (*Export, os.Error) {
+	f, err := parser.ParseFile(filename, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &Export{path, f}, nil
+}
+
+// Name returns the name of the exporting package.
+func (exp *Export) Name() string { return exp.File.Name.Name }
+
+// Func returns the declaration of the exported function name, or nil.
+func (exp *Export) Func(name string) *ast.FuncDecl {
+	for _, d := range exp.File.Decls {
+		if f, ok := d.(*ast.FuncDecl); ok && f.Name.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// Imports returns the package names seen by the exported functions,
+// including the qualifier used for the package itself.
+func (exp *Export) Imports() map[string]string {
+	paths := transform.Imports(exp.File)
+	paths[exp.Name()] = exp.Path
+	return paths
+}
+
+// WriteExport writes the export file for pkg to w.  Only exported
+// functions whose bodies refer to no unexported top-level names,
+// fields or methods are recorded, since no other body could be
+// inlined into an importer.
+// A node of a type that the transforms don't know is reported as an
+// error.
+func WriteExport(w io.Writer, pkg *ast.Package) (err os.Error) {
+	defer transform.CatchUnknownNode(&err)
+	toplevel := make(map[string]bool)
+	for _, f := range pkg.Files {
+		for _, d := range f.Decls {
+			switch d := d.(type) {
+			case *ast.FuncDecl:
+				if d.Recv == nil {
+					toplevel[d.Name.Name] = true
+				}
+			case *ast.GenDecl:
+				for _, s := range d.Specs {
+					switch s := s.(type) {
+					case *ast.TypeSpec:
+						toplevel[s.Name.Name] = true
+					case *ast.ValueSpec:
+						for _, n := range s.Names {
+							toplevel[n.Name] = true
+						}
+					}
+				}
+			}
+		}
+	}
+
+	var nopos token.Position
+	out := &ast.File{nil, nopos, ast.NewIdent(pkg.Name), nil, nil}
+	var decls vector.Vector
+	for _, f := range pkg.Files {
+		for _, d := range f.Decls {
+			d, ok := d.(*ast.FuncDecl)
+			if !ok || d.Recv != nil || d.Body == nil || !ast.IsExported(d.Name.Name) {
+				continue
+			}
+			q := qualifier{pkg.Name, toplevel, locals(d), true}
+			d = transform.Copy(d).(*ast.FuncDecl)
+			transform.Walk(&q, d.Type)
+			transform.Walk(&q, d.Body)
+			if !q.ok {
+				continue
+			}
+			r := requalifier{transform.Imports(f), out, make(map[string]string)}
+			transform.Walk(&r, d)
+			decls.Push(d)
+		}
+	}
+
+	// the requalifier has added the imports to out.Decls
+	all := make([]ast.Decl, len(out.Decls)+len(decls))
+	copy(all, out.Decls)
+	for i, x := range decls {
+		all[len(out.Decls)+i] = x.(ast.Decl)
+	}
+	out.Decls = all
+
+	return printer.Fprint(w, out)
+}
+
+// A qualifier qualifies references to the top-level names of a
+// package by the package name.  It clears ok if it finds a reference
+// to a name that cannot be qualified, or to an unexported field or
+// method.  Keys in composite literals are
+// qualified too, so a struct field sharing its name with an exported
+// top-level name will confuse it.
+type qualifier struct {
+	pkg      string
+	toplevel map[string]bool
+	locals   map[string]bool
+	ok       bool
+}
+
+func (v *qualifier) Visit(node interface{}) interface{} {
+	switch n := node.(type) {
+	case *ast.SelectorExpr:
+		// the selector is a field or method name, never a top-level
+		// name, and can't be reached from another package unless it is
+		// exported
+		if !ast.IsExported(n.Sel.Name) {
+			v.ok = false
+		}
+		n.X = transform.Walk(v, n.X).(ast.Expr)
+		return n
+	case *ast.LabeledStmt:
+		n.Stmt = transform.Walk(v, n.Stmt).(ast.Stmt)
+		return n
+	case *ast.BranchStmt:
+		return n
+	case *ast.Ident:
+		// Returning the ident itself keeps Walk from replacing it.
+		if !v.toplevel[n.Name] {
+			return n
+		}
+		if v.locals[n.Name] || !ast.IsExported(n.Name) {
+			v.ok = false
+			return n
+		}
+		return &ast.SelectorExpr{&ast.Ident{n.Pos(), v.pkg, nil}, n}
+	}
+	return nil
+}
+
+// locals returns the names declared anywhere within d, other than at
+// the top level.
+func locals(d *ast.FuncDecl) map[string]bool {
+	v := localCollector(make(map[string]bool))
+	transform.Walk(v, d.Type)
+	if d.Body != nil {
+		transform.Walk(v, d.Body)
+	}
+	return v
+}
+
+type localCollector map[string]bool
+
+func (v localCollector) Visit(node interface{}) interface{} {
+	switch n := node.(type) {
+	case *ast.Field:
+		for _, id := range n.Names {
+			v[id.Name] = true
+		}
+	case *ast.ValueSpec:
+		for _, id := range n.Names {
+			v[id.Name] = true
+		}
+	case *ast.TypeSpec:
+		v[n.Name.Name] = true
+	case *ast.AssignStmt:
+		if n.Tok == token.DEFINE {
+			for _, x := range n.Lhs {
+				if id, ok := x.(*ast.Ident); ok {
+					v[id.Name] = true
+				}
+			}
+		}
+	case *ast.RangeStmt:
+		if n.Tok == token.DEFINE {
+			for _, x := range []ast.Expr{n.Key, n.Value} {
+				if id, ok := x.(*ast.Ident); ok {
+					v[id.Name] = true
+				}
+			}
+		}
+	}
+	return nil
+}
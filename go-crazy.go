@@ -1,18 +1,62 @@
 package main
 
 import (
+	"container/vector"
 	"fmt"
+	"io"
 	"os"
 	"exec"
+	"strings"
 	"github.com/droundy/goopt"
 	"github.com/droundy/go-crazy/parser"
+	"github.com/droundy/go-crazy/scanner"
+	"github.com/droundy/go-crazy/transform"
+	"go/ast"
 	"go/printer"
 )
 
 var just_translate = goopt.Flag([]string{"--just-translate"}, []string{},
 	"just build the -compiled.go file", "build and compile and link")
 
-var toinline = goopt.Strings([]string{"--inline"}, "FUNC", "specify function to inline")
+var rewrites = goopt.Strings([]string{"-r", "--rewrite"}, "'PATTERN -> REPLACEMENT'",
+	"rewrite each match of PATTERN, in which single lower-case letters are wildcards")
+
+var bindings = goopt.Strings([]string{"--bind-operator"}, "'TYPE OP METHOD [recv|dest]'",
+	"make OP on TYPE call METHOD of its receiver (recv), or of a new TYPE given both operands (dest)")
+
+var toinline = goopt.Strings([]string{"--inline"}, "FUNC", "specify function (or PKG.FUNC) to inline")
+
+var autoinline = goopt.Flag([]string{"--inline-auto"}, []string{},
+	"inline small leaf functions and operator methods", "only inline functions named with --inline")
+
+var inlinebudget = goopt.Int([]string{"--inline-budget"}, 40,
+	"largest estimated cost of a function to inline automatically")
+
+var inlinedepth = goopt.Int([]string{"--inline-depth"}, 0,
+	"how many levels of calls to a recursive function to inline")
+
+var exportinline = goopt.String([]string{"--export-inline"}, "",
+	"write the inlinable exported functions to FILE")
+
+var inlinefrom = goopt.Strings([]string{"--inline-from"}, "PATH=FILE",
+	"read inlinable functions of the package imported as PATH from FILE")
+
+var inlinereport = goopt.Flag([]string{"--inline-report"}, []string{},
+	"print what became of each call that could be inlined, and of each function --inline-auto considered", "don't report on inlining")
+
+var inlinejson = goopt.String([]string{"--inline-report-json"}, "",
+	"write the inlining report to FILE as JSON")
+
+var passlist = goopt.String([]string{"--passes"}, "",
+	"comma-separated list of the passes to run, in order (see --list-transforms)")
+
+var dumpafter = goopt.Strings([]string{"--dump-after"}, "PASS", "print the program after PASS")
+
+var timepasses = goopt.Flag([]string{"--time-passes"}, []string{},
+	"print the time taken by each pass", "don't time the passes")
+
+var listtransforms = goopt.Flag([]string{"--list-transforms"}, []string{},
+	"list the passes available, including those of plugins", "don't list the passes")
 
 func panicon(err os.Error) {
 	if err != nil {
@@ -31,34 +75,123 @@ func archnum() string {
 
 func main() {
 	goopt.Parse(func() []string { return []string{} })
-	if len(goopt.Args) != 1 {
-		fmt.Println("We need the name of a go file to process!")
+	if *listtransforms {
+		ListPasses(os.Stdout)
+		os.Exit(0)
+	}
+	if len(goopt.Args) == 0 {
+		fmt.Println("We need the names of the go files to process!")
 		os.Exit(1)
 	}
-	filename := goopt.Args[0]
+	filenames := goopt.Args
 
-	fileast,err := parser.ParseFile(filename, nil, parser.ParseComments)
+	pkgs,err := parser.ParseFiles(filenames, parser.ParseComments)
 	if err != nil {
 		fmt.Println("Parse error:\n", err)
 		os.Exit(1)
 	}
+	if len(pkgs) != 1 {
+		fmt.Println("The go files must all belong to the same package!")
+		os.Exit(1)
+	}
+	var pkg *ast.Package
+	for _,p := range pkgs {
+		pkg = p
+	}
+
+	if *exportinline != "" {
+		out,err := os.Open(*exportinline, os.O_WRONLY + os.O_TRUNC + os.O_CREAT, 0644)
+		panicon(err)
+		panicon(WriteExport(out, pkg))
+		out.Close()
+	}
+
+	exports := make([]*Export, len(*inlinefrom))
+	for i,spec := range *inlinefrom {
+		eq := strings.Index(spec, "=")
+		if eq < 0 {
+			fmt.Println("Bad --inline-from, expected PATH=FILE:", spec)
+			os.Exit(1)
+		}
+		exports[i],err = ReadExport(spec[0:eq], spec[eq+1:])
+		if err != nil {
+			fmt.Println("Error reading export file:\n", err)
+			os.Exit(1)
+		}
+	}
+
+	binds := make([]*OperatorBinding, len(*bindings))
+	for i,spec := range *bindings {
+		binds[i],err = ParseBinding(spec)
+		if err != nil {
+			fmt.Println("Bad --bind-operator:", err)
+			os.Exit(1)
+		}
+	}
 
-	for _,fname := range *toinline {
-		fileast = Inline(fileast, fname)
+	var report *InlineReport
+	if *inlinereport || *inlinejson != "" {
+		report = new(InlineReport)
+	}
+	st := &PassState{exports, report, binds, make(transform.Origins), transform.NewCommentMap(pkg)}
+	names := splitList(*passlist)
+	if *passlist == "" {
+		names = defaultPasses(pkg)
+	}
+	list,err := SchedulePasses(names)
+	if err != nil {
+		fmt.Println("Bad --passes:", err)
+		os.Exit(1)
+	}
+	dumps := make(map[string]bool)
+	for _,name := range *dumpafter {
+		if _,ok := passes[name]; !ok {
+			fmt.Println("Bad --dump-after: unknown pass:", name)
+			os.Exit(1)
+		}
+		dumps[name] = true
+	}
+	var times io.Writer
+	if *timepasses {
+		times = os.Stdout
+	}
+	pkg,err = RunPasses(pkg, list, st, dumps, times)
+	if perr,ok := err.(*PassError); ok {
+		fmt.Println("Error in pass", perr.Pass+":")
+		scanner.PrintError(os.Stdout, perr.Err)
+		os.Exit(1)
+	} else if err != nil {
+		fmt.Println("Error running passes:", err)
+		os.Exit(1)
+	}
+	if *inlinereport {
+		panicon(report.Write(os.Stdout))
+	}
+	if *inlinejson != "" {
+		out,err := os.Open(*inlinejson, os.O_WRONLY + os.O_TRUNC + os.O_CREAT, 0644)
+		panicon(err)
+		panicon(report.WriteJSON(out))
+		out.Close()
 	}
 
-	// Let's create a file containing the parsed code...
-	basename := filename[0:len(filename)-3]
-	newfilename := basename+"-compiled.go"
-	out,err := os.Open(newfilename, os.O_WRONLY + os.O_TRUNC + os.O_CREAT, 0644)
-	panicon(err)
-	panicon(printer.Fprint(out, fileast))
-	out.Close()
+	// Let's create files containing the parsed code...
+	newfilenames := make([]string, len(filenames))
+	for i,filename := range filenames {
+		newfilenames[i] = filename[0:len(filename)-3]+"-compiled.go"
+		out,err := os.Open(newfilenames[i], os.O_WRONLY + os.O_TRUNC + os.O_CREAT, 0644)
+		panicon(err)
+		panicon(printer.Fprint(out, pkg.Files[filename]))
+		out.Close()
+	}
 
 	if !*just_translate {
+		basename := filenames[0][0:len(filenames[0])-3]
 		objname := basename+"-compiled."+archnum()
-		if e := justrun(archnum()+"g", "-o", objname, newfilename); e != nil {
-			fmt.Println("Error compiling", newfilename,"!")
+		args := make([]string, len(newfilenames)+2)
+		args[0], args[1] = "-o", objname
+		copy(args[2:], newfilenames)
+		if e := justrun(archnum()+"g", args...); e != nil {
+			fmt.Println("Error compiling", basename,"!")
 			fmt.Println(e)
 			os.Exit(1)
 		}
@@ -66,6 +199,37 @@ func main() {
 	}
 }
 	
+// defaultPasses returns the passes that the flags ask for, in the
+// order registered, followed by those of the plugins, the lowering of
+// dotted operators on built-in types if pkg uses any operator methods,
+// and the removal of unused imports if any of the other passes are run.
+func defaultPasses(pkg *ast.Package) []string {
+	var names vector.StringVector
+	if len(*rewrites) > 0 {
+		names.Push("rewrite")
+	}
+	if len(*bindings) > 0 {
+		names.Push("bind-operators")
+	}
+	if len(*toinline) > 0 {
+		names.Push("inline")
+	}
+	if *autoinline {
+		names.Push("inline-auto")
+	}
+	for _, p := range transform.Plugins() {
+		names.Push(p.Name)
+	}
+	cleanup := names.Len() > 0
+	if usesOperators(pkg) {
+		names.Push("lower-operators")
+	}
+	if cleanup {
+		names.Push("unused-imports")
+	}
+	return names
+}
+
 func justrun(cmd string, args ...string) os.Error {
 	abscmd,err := exec.LookPath(cmd)
 	if err != nil { return os.NewError("Couldn't find "+cmd+": "+err.String()) }
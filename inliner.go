@@ -1,61 +1,500 @@
 package main
 
 import (
+	"fmt"
 	"go/ast"
 	"go/token"
+	"os"
 	"github.com/droundy/go-crazy/transform"
 )
 
-func Inline(fast *ast.File, name string) *ast.File {
-	extractor := ExtractFunctionDeclaration{name, nil}
-	out := transform.Walk(&extractor, fast).(*ast.File)
-	inliner := InlineFunction(extractor)
-	return transform.Walk(&inliner, out).(*ast.File)
+// Inline inlines every call to the function name, which must be
+// declared in pkg.  Unless name is recursive, its declaration is
+// removed.  A recursive function is kept, and calls to it are inlined
+// to at most depth levels, which unrolls its recursion when depth is
+// not zero.  The calls are recorded in st.Report, and the positions
+// and comments of the code inlined in st.Origins and st.Comments.
+func Inline(pkg *ast.Package, name string, depth int, st *PassState) (*ast.Package, os.Error) {
+	for _, f := range pkg.Files {
+		for _, d := range f.Decls {
+			if d, ok := d.(*ast.FuncDecl); ok && d.Recv == nil && d.Name.Name == name {
+				if newCallGraph(pkg, nil).recursive(d) {
+					inliner := NewInliner(st)
+					inliner.AddFunc(d, f)
+					// the calls within d are inlined as well
+					inliner.Preserve(d)
+					inliner.depth = depth
+					inliner.recursive[d] = true
+					return inlinePackage(inliner, pkg)
+				}
+			}
+		}
+	}
+
+	extractor := ExtractFunctionDeclaration{name, nil, nil, transform.NewGensym(pkg), st.Origins, st.Comments, nil}
+	out := transform.Walk(&extractor, pkg).(*ast.Package)
+	if extractor.ItsDecl == nil {
+		return pkg, os.NewError("no inlinable function " + name)
+	}
+	inliner := NewInliner(st)
+	inliner.AddFunc(extractor.ItsDecl, extractor.ItsFile)
+	return inlinePackage(inliner, out)
 }
 
-type InlineFunction ExtractFunctionDeclaration
+// InlineImported inlines calls to the function name recorded in exp,
+// to at most depth levels if it is recursive.
+func InlineImported(pkg *ast.Package, exp *Export, name string, depth int, st *PassState) (*ast.Package, os.Error) {
+	decl := exp.Func(name)
+	if decl == nil {
+		return pkg, os.NewError("no inlinable function " + exp.Name() + "." + name)
+	}
+	inliner := NewInliner(st)
+	inliner.AddImported(exp, decl)
+	inliner.depth = depth
+	inliner.recursive[decl] = newCallGraph(pkg, []*Export{exp}).recursive(decl)
+	return inlinePackage(inliner, pkg)
+}
 
-func (v *InlineFunction) Visit(node interface{}) interface{} {
-	if n,ok := node.(*ast.CallExpr); ok {
-		if id,ok := n.Fun.(*ast.Ident); ok && id.Name == v.Name {
-			return &ast.ParenExpr {
-				n.Pos(),
-				&ast.CallExpr{
-					&ast.FuncLit{
-						v.ItsDecl.Type,
-						v.ItsDecl.Body,
-					},
-					n.Lparen,
-					n.Args,
-					n.Ellipsis,
-					n.Rparen,
-				},
-				n.Rparen,
+// inlinePackage walks pkg with inliner, returning the errors it
+// reports, or pkg itself if the walk meets a node of an unknown type.
+func inlinePackage(inliner *Inliner, pkg *ast.Package) (*ast.Package, os.Error) {
+	out, err := transform.WalkErrors(inliner, pkg)
+	if out == nil {
+		return pkg, err
+	}
+	return out.(*ast.Package), err
+}
+
+// An Inliner replaces calls to the functions and methods it has been
+// given with calls to function literals holding their bodies.  When
+// a body is inlined into a file other than the one declaring it, the
+// package names it refers to are rewritten to those imported by that
+// file, and missing imports are added.
+//
+// Methods are matched by name alone, so an Inliner should only be
+// given methods whose name is declared for a single type.
+//
+// Calls to recursive functions are inlined to at most depth levels,
+// leaving the calls below that level in place.  Each level is a fresh
+// copy of the function body, in which the calls are inlined in turn.
+//
+// If the Inliner has a report, every call to a function it has been
+// given is recorded there, including calls to functions that have
+// been rejected.
+//
+// Inlined code is given the position of the call it replaces, and
+// the Inliner records the positions it had in its origins.  The
+// comments of a function body inlined as a closure go with it.
+//
+// A call to a function that cannot be inlined, such as one declared
+// without a body, is reported as an error and left in place.
+//
+// When it walks a Package, the Inliner resolves the names within it,
+// so that a call through a local name shadowing a function, or a
+// method call on a variable shadowing a package name, is left alone.
+// The locals of the caller that share their names with the top-level,
+// package or predeclared names an inlined body refers to are renamed,
+// so that they don't capture them.
+type Inliner struct {
+	funcs    map[string]*ast.FuncDecl
+	methods  map[string]*ast.FuncDecl
+	imported map[string]map[string]*ast.FuncDecl // by import path, then name
+	files    map[*ast.FuncDecl]*ast.File         // where each declaration lives
+	imports  map[*ast.FuncDecl]map[string]string // the packages each declaration sees
+	names    map[*ast.FuncDecl]string            // for the report
+	rejected map[*ast.FuncDecl]string            // why not to inline a declaration
+	original map[*ast.FuncDecl]*ast.FuncDecl     // copies of declarations to inline from
+	report   *InlineReport
+	origins  transform.Origins     // where inlined code came from
+	comments *transform.CommentMap // the comments of the package being walked
+
+	recursive map[*ast.FuncDecl]bool
+	depth     int // how deep to inline recursive functions
+	level     int // how deep we are within inlined recursive functions
+
+	resolution *transform.Resolution           // of the package being walked
+	file       *ast.File                       // the file being walked
+	decl       *ast.FuncDecl                   // the function being walked
+	paths      map[string]string               // the packages imported by file
+	adapted    map[*ast.FuncDecl]*ast.FuncDecl // declarations adapted to file
+}
+
+// NewInliner returns an Inliner recording what it does in st.
+func NewInliner(st *PassState) *Inliner {
+	return &Inliner{
+		make(map[string]*ast.FuncDecl),
+		make(map[string]*ast.FuncDecl),
+		make(map[string]map[string]*ast.FuncDecl),
+		make(map[*ast.FuncDecl]*ast.File),
+		make(map[*ast.FuncDecl]map[string]string),
+		make(map[*ast.FuncDecl]string),
+		make(map[*ast.FuncDecl]string),
+		make(map[*ast.FuncDecl]*ast.FuncDecl),
+		st.Report,
+		st.Origins,
+		st.Comments,
+		make(map[*ast.FuncDecl]bool),
+		0, 0,
+		nil, nil, nil, nil, nil,
+	}
+}
+
+// AddFunc arranges for calls to decl, a function or method declared
+// in file, to be inlined.
+func (v *Inliner) AddFunc(decl *ast.FuncDecl, file *ast.File) {
+	if decl.Recv != nil {
+		v.methods[decl.Name.Name] = decl
+	} else {
+		v.funcs[decl.Name.Name] = decl
+	}
+	v.files[decl] = file
+	v.imports[decl] = transform.Imports(file)
+	v.names[decl] = funcName(decl, "")
+}
+
+// AddImported arranges for calls to decl, a function recorded in
+// exp, to be inlined into the packages importing exp.Path.
+func (v *Inliner) AddImported(exp *Export, decl *ast.FuncDecl) {
+	funcs, ok := v.imported[exp.Path]
+	if !ok {
+		funcs = make(map[string]*ast.FuncDecl)
+		v.imported[exp.Path] = funcs
+	}
+	funcs[decl.Name.Name] = decl
+	v.files[decl] = exp.File
+	v.imports[decl] = exp.Imports()
+	v.names[decl] = funcName(decl, exp.Name())
+}
+
+// Preserve arranges for calls to decl, which must already have been
+// given to the Inliner, to be inlined from a copy of decl as it is
+// now, so that the calls inlined within decl itself, as those of a
+// recursive function are, are not inlined again with it.
+func (v *Inliner) Preserve(decl *ast.FuncDecl) {
+	d := transform.Copy(decl).(*ast.FuncDecl)
+	v.comments.Duplicate(decl, d)
+	v.files[d] = v.files[decl]
+	v.imports[d] = v.imports[decl]
+	v.original[decl] = d
+}
+
+// Reject arranges for calls to decl, which must already have been
+// given to the Inliner, to be reported but not inlined.
+func (v *Inliner) Reject(decl *ast.FuncDecl, reason string) {
+	v.rejected[decl] = reason
+}
+
+func (v *Inliner) Visit(node interface{}, errs *transform.Errors) interface{} {
+	switch n := node.(type) {
+	case *ast.Package:
+		v.resolution = transform.Resolve(n)
+	case *ast.File:
+		v.file = n
+		v.decl = nil
+		v.paths = transform.Imports(n)
+		v.adapted = make(map[*ast.FuncDecl]*ast.FuncDecl)
+	case *ast.FuncDecl:
+		v.decl = n
+	case *ast.CallExpr:
+		decl, recv := v.callee(n)
+		if decl == nil {
+			return nil
+		}
+		if reason, ok := v.rejected[decl]; ok {
+			v.report.Add(&InlineSite{n.Pos(), v.names[decl], false, "", reason, 0})
+			return nil
+		}
+		if v.recursive[decl] && v.level >= v.depth {
+			reason := "recursive"
+			if v.depth > 0 {
+				reason = fmt.Sprintf("recursion deeper than %d", v.depth)
 			}
+			v.report.Add(&InlineSite{n.Pos(), v.names[decl], false, "", reason, 0})
+			return nil
+		}
+		if decl.Body == nil {
+			errs.Errorf(n.Pos(), "cannot inline %s: it has no body", v.names[decl])
+			v.report.Add(&InlineSite{n.Pos(), v.names[decl], false, "", "no body", 0})
+			return nil
+		}
+		pos := n.Pos()
+		// Walk won't descend into the replacement, so inline the
+		// operands first.
+		if recv != nil {
+			recv = errs.Walk(v, recv).(ast.Expr)
+		}
+		n.Args = errs.Walk(v, n.Args).([]ast.Expr)
+		// the inlined code is placed at the call, leaving a record of
+		// where it came from
+		src := decl
+		if o, ok := v.original[decl]; ok {
+			src = o
 		}
+		d := transform.Copy(v.adapt(src)).(*ast.FuncDecl)
+		v.comments.Duplicate(src, d)
+		transform.Relocate(d, pos, v.origins)
+		if v.recursive[decl] {
+			v.level++
+			d.Body = errs.Walk(v, d.Body).(*ast.BlockStmt)
+			v.level--
+		}
+		v.unshadow(d)
+		x := inlineCall(d, recv, n)
+		growth := countNodes(x) - countNodes(n)
+		v.report.Add(&InlineSite{pos, v.names[decl], true, "closure", "", growth})
+		return x
 	}
 	return nil
 }
 
+// callee returns the declaration of the function called by n if it
+// is to be inlined, and the receiver expression if it is a method.
+func (v *Inliner) callee(n *ast.CallExpr) (*ast.FuncDecl, ast.Expr) {
+	switch f := n.Fun.(type) {
+	case *ast.Ident:
+		decl, ok := v.funcs[f.Name]
+		if d := v.resolution.Decl(f); ok && d != nil && d != decl.Name {
+			return nil, nil // a local name shadows the function
+		}
+		return decl, nil
+	case *ast.SelectorExpr:
+		// a package name may be shadowed by a local variable
+		if x, ok := f.X.(*ast.Ident); ok && v.resolution.Decl(x) == nil {
+			if path, ok := v.paths[x.Name]; ok {
+				if funcs, ok := v.imported[path]; ok {
+					return funcs[f.Sel.Name], nil
+				}
+				return nil, nil
+			}
+		}
+		if decl, ok := v.methods[f.Sel.Name]; ok {
+			return decl, f.X
+		}
+	}
+	return nil, nil
+}
+
+// adapt returns a version of decl that may be inlined into the file
+// being walked.
+func (v *Inliner) adapt(decl *ast.FuncDecl) *ast.FuncDecl {
+	if v.files[decl] == v.file {
+		return decl
+	}
+	if d, ok := v.adapted[decl]; ok {
+		return d
+	}
+	d := transform.Copy(decl).(*ast.FuncDecl)
+	if v.mayCapture(d) {
+		transform.AlphaRename(d, transform.NewGensym(v.file))
+	}
+	r := requalifier{v.imports[decl], v.file, make(map[string]string)}
+	transform.Walk(&r, d)
+	v.adapted[decl] = d
+	return d
+}
+
+// mayCapture returns true if a local name in decl could be mistaken
+// for a package name once decl is requalified for the file being
+// walked.
+func (v *Inliner) mayCapture(decl *ast.FuncDecl) bool {
+	names := make(map[string]bool)
+	for name := range v.paths {
+		names[name] = true
+	}
+	for _, path := range v.imports[decl] {
+		names[transform.DefaultImportName(path)] = true
+	}
+	for name := range locals(decl) {
+		if names[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// unshadow renames the locals of the function being walked that
+// share their names with the names the body of decl, which is to be
+// inlined into it, refers to without declaring them.
+func (v *Inliner) unshadow(decl *ast.FuncDecl) {
+	if v.decl == nil {
+		return
+	}
+	s := shadower{v.resolution, freeNames(decl), make(map[*ast.Ident]string)}
+	walk := func() {
+		if v.decl.Recv != nil {
+			transform.Walk(&s, v.decl.Recv)
+		}
+		transform.Walk(&s, v.decl.Type)
+		if v.decl.Body != nil {
+			transform.Walk(&s, v.decl.Body)
+		}
+	}
+	walk()
+	if len(s.names) == 0 {
+		return
+	}
+	g := transform.NewGensym(v.file, decl)
+	for id := range s.names {
+		s.names[id] = g.Name(id.Name)
+	}
+	s.free = nil
+	walk()
+}
+
+// A shadower finds the declarations of the names in free, recording
+// them in names, or if free is nil, renames them and their uses as
+// names says.
+type shadower struct {
+	resolution *transform.Resolution
+	free       map[string]bool
+	names      map[*ast.Ident]string // by declaring identifier
+}
+
+func (v *shadower) Visit(node interface{}) interface{} {
+	if id, ok := node.(*ast.Ident); ok {
+		d := v.resolution.Decl(id)
+		if v.free != nil {
+			if d == id && v.free[id.Name] {
+				v.names[id] = ""
+			}
+		} else if name, ok := v.names[d]; ok {
+			id.Name = name
+		}
+	}
+	return nil
+}
+
+// freeNames returns the names that the body of decl refers to without
+// declaring them, such as top-level, package and predeclared names.
+// The names of fields and methods and labels are left out, but keys
+// of composite literals are not, since they may be names of either
+// kind.
+func freeNames(decl *ast.FuncDecl) map[string]bool {
+	v := freeCollector{transform.Resolve(decl), make(map[string]bool)}
+	if decl.Body != nil {
+		transform.Walk(&v, decl.Body)
+	}
+	return v.names
+}
+
+type freeCollector struct {
+	resolution *transform.Resolution
+	names      map[string]bool
+}
+
+func (v *freeCollector) Visit(node interface{}) interface{} {
+	switch n := node.(type) {
+	case *ast.Ident:
+		if v.resolution.Decl(n) == nil {
+			v.names[n.Name] = true
+		}
+	case *ast.SelectorExpr:
+		transform.Walk(v, n.X)
+		return n
+	case *ast.LabeledStmt:
+		transform.Walk(v, n.Stmt)
+		return n
+	case *ast.BranchStmt:
+		return n
+	}
+	return nil
+}
+
+// A requalifier rewrites the package names in a copied declaration
+// to those under which a different file imports the same packages.
+type requalifier struct {
+	from  map[string]string // package names seen by the declaration, and their paths
+	to    *ast.File
+	names map[string]string // package names in from, and their names in to
+}
+
+func (v *requalifier) Visit(node interface{}) interface{} {
+	if n, ok := node.(*ast.SelectorExpr); ok {
+		if x, ok := n.X.(*ast.Ident); ok {
+			if path, ok := v.from[x.Name]; ok {
+				name, ok := v.names[x.Name]
+				if !ok {
+					name = transform.AddImport(v.to, path)
+					v.names[x.Name] = name
+				}
+				x.Name = name
+			}
+		}
+	}
+	return nil
+}
+
+// inlineCall returns an expression that calls a function literal
+// holding the body of decl in place of call.  If decl is a method,
+// recv is the receiver expression of the call, which is passed to
+// the literal as its first argument.
+func inlineCall(decl *ast.FuncDecl, recv ast.Expr, call *ast.CallExpr) ast.Expr {
+	typ := decl.Type
+	args := call.Args
+	if recv != nil {
+		params := make([]*ast.Field, 1+len(decl.Type.Params.List))
+		params[0] = decl.Recv.List[0]
+		copy(params[1:], decl.Type.Params.List)
+		for i, f := range params {
+			if len(f.Names) == 0 {
+				// A receiver and its parameters may be anonymous,
+				// but the parameters of a literal must all be named
+				// or all be anonymous.
+				params[i] = &ast.Field{f.Doc, []*ast.Ident{ast.NewIdent("_")}, f.Type, f.Tag, f.Comment}
+			}
+		}
+		paramlist := *decl.Type.Params
+		paramlist.List = params
+		functype := *decl.Type
+		functype.Params = &paramlist
+		typ = &functype
+		args = make([]ast.Expr, 1+len(call.Args))
+		args[0] = recv
+		copy(args[1:], call.Args)
+	}
+	return &ast.ParenExpr {
+		call.Pos(),
+		&ast.CallExpr{
+			&ast.FuncLit{
+				typ,
+				decl.Body,
+			},
+			call.Lparen,
+			args,
+			call.Ellipsis,
+			call.Rparen,
+		},
+		call.Rparen,
+	}
+}
+
 type ExtractFunctionDeclaration struct {
 	Name string
 	ItsDecl *ast.FuncDecl
+	ItsFile *ast.File // the file declaring ItsDecl
+	Gensym *transform.Gensym
+	Origins transform.Origins // where the generated declaration came from
+	Comments *transform.CommentMap // the comments to move to it
+	file *ast.File    // the file being walked
 }
 
 func (v *ExtractFunctionDeclaration) Visit(node interface{}) interface{} {
 	switch n := node.(type) {
+	case *ast.File:
+		v.file = n
 	case *ast.FuncDecl:
-		if n.Name.Name == v.Name {
+		if n.Recv == nil && n.Name.Name == v.Name {
 			v.ItsDecl = n
+			v.ItsFile = v.file
 			var nopos token.Position
-			return &ast.GenDecl{
+			d := &ast.GenDecl{
 				n.Doc,
 				n.Pos(),
 				token.CONST,
 				nopos,
 				[]ast.Spec{&ast.ValueSpec{
 						nil,
-						[]*ast.Ident{ast.NewIdent("i_inlined_"+v.Name)},
+						[]*ast.Ident{v.Gensym.Ident("i_inlined_"+v.Name)},
 						nil,
 						[]ast.Expr{&ast.BasicLit{nopos, token.INT, []byte("0")}},
 						nil,
@@ -63,6 +502,9 @@ func (v *ExtractFunctionDeclaration) Visit(node interface{}) interface{} {
 				},
 				nopos,
 			}
+			transform.Synthesize(d, n, v.Origins)
+			v.Comments.Move(n, d)
+			return d
 		}
 	}
 	return nil
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"github.com/droundy/go-crazy/transform"
+)
+
+// operatorTokens maps the methods called by the dotted operators to
+// the operators they stand for.
+var operatorTokens = map[string]token.Token{
+	"_dot_add": token.ADD,
+	"_dot_sub": token.SUB,
+	"_dot_mul": token.MUL,
+	"_dot_quo": token.QUO,
+	"_mul_dot": token.MUL,
+}
+
+// dottedOperands returns the operands of the dotted operator call x
+// as written, together with the operator it stands for, or ok false
+// if x is not such a call.
+func dottedOperands(x *ast.CallExpr) (left, right ast.Expr, op token.Token, ok bool) {
+	sel, isSel := x.Fun.(*ast.SelectorExpr)
+	if !isSel || len(x.Args) != 1 {
+		return nil, nil, token.ILLEGAL, false
+	}
+	op, ok = operatorTokens[sel.Sel.Name]
+	if sel.Sel.Name == "_mul_dot" {
+		return x.Args[0], sel.X, op, ok
+	}
+	return sel.X, x.Args[0], op, ok
+}
+
+// usesOperators returns true if pkg calls, declares or names any
+// operator method, and so needs the passes handling dotted operators.
+func usesOperators(pkg *ast.Package) bool {
+	found := transform.Query(pkg, func(m *transform.Match) bool {
+		id, ok := m.Node.(*ast.Ident)
+		return ok && isOperatorMethod(id.Name)
+	})
+	return len(found) > 0
+}
+
+// LowerOperators replaces each dotted operator in pkg whose receiver
+// has no method for it, and whose operands are numbers or slices of
+// numbers, by the ordinary operator or by a loop applying the
+// operator element by element.  Types are found by
+// transform.InferTypes, and operators whose operand types it can't
+// find are left alone.  The positions of the code generated are
+// recorded in st.Origins.
+func LowerOperators(pkg *ast.Package, st *PassState) *ast.Package {
+	types := transform.InferTypes(pkg)
+	post := func(c *transform.Cursor) bool {
+		if call, ok := c.Node().(*ast.CallExpr); ok {
+			if x := lowerOperator(types, call); x != nil {
+				if _, ok := c.Parent().(*ast.SelectorExpr); ok {
+					// the receiver of a dotted operator left alone
+					var nopos token.Position
+					x = &ast.ParenExpr{nopos, x, nopos}
+				}
+				transform.Synthesize(x, call, st.Origins)
+				c.Replace(x)
+			}
+		}
+		return true
+	}
+	return transform.Apply(pkg, nil, post).(*ast.Package)
+}
+
+// lowerOperator returns the lowered form of the dotted operator call
+// x, or nil if it can't or mustn't be lowered.
+func lowerOperator(types *transform.Types, x *ast.CallExpr) ast.Expr {
+	left, right, op, ok := dottedOperands(x)
+	if !ok {
+		return nil
+	}
+	sel := x.Fun.(*ast.SelectorExpr)
+	if types.Method(types.TypeOf(sel.X), sel.Sel.Name) != nil {
+		return nil
+	}
+	lt, rt := types.TypeOf(left), types.TypeOf(right)
+	le, re := numericElem(types, lt, sel.Sel.Name), numericElem(types, rt, sel.Sel.Name)
+	switch {
+	case le == nil && re == nil:
+		if types.IsNumeric(lt) && types.IsNumeric(rt) {
+			return &ast.BinaryExpr{left, sel.Sel.Pos(), op, right}
+		}
+	case le != nil && re != nil:
+		return elementwise(left, right, lt, rt, lt, op, true, true)
+	case le != nil && types.IsNumeric(rt):
+		if types.Untyped(right) {
+			rt = le
+		}
+		return elementwise(left, right, lt, rt, lt, op, true, false)
+	case re != nil && types.IsNumeric(lt):
+		if types.Untyped(left) {
+			lt = re
+		}
+		return elementwise(left, right, lt, rt, rt, op, false, true)
+	}
+	return nil
+}
+
+// numericElem returns the element type of typ if it is a slice of
+// numbers lacking the method name, and nil otherwise.
+func numericElem(types *transform.Types, typ ast.Expr, name string) ast.Expr {
+	if a, ok := types.Underlying(typ).(*ast.ArrayType); ok && a.Len == nil {
+		if types.IsNumeric(a.Elt) && types.Method(a.Elt, name) == nil {
+			return a.Elt
+		}
+	}
+	return nil
+}
+
+// elementwise returns a call to a function literal applying op to
+// left and right element by element, where lslice and rslice say
+// which of them are slices, and returning a new slice of type
+// result.  Slices of different lengths make it panic.
+//
+//	func(a lt, b rt) result {
+//		c := make(result, len(a))
+//		for i := range c {
+//			c[i] = a[i] op b[i]
+//		}
+//		return c
+//	}(left, right)
+//
+func elementwise(left, right, lt, rt, result ast.Expr, op token.Token, lslice, rslice bool) ast.Expr {
+	lt, rt, result = transform.Copy(lt).(ast.Expr), transform.Copy(rt).(ast.Expr), transform.Copy(result).(ast.Expr)
+	g := transform.NewGensym(lt, rt, result)
+	a, b, c, i := g.Name("a"), g.Name("b"), g.Name("c"), g.Name("i")
+	id := ast.NewIdent
+	var nopos token.Position
+	elem := func(s string, slice bool) ast.Expr {
+		if slice {
+			return &ast.IndexExpr{id(s), id(i)}
+		}
+		return id(s)
+	}
+	length := a
+	if !lslice {
+		length = b
+	}
+	var body []ast.Stmt
+	if lslice && rslice {
+		differ := &ast.BinaryExpr{builtinCall("len", id(a)), nopos, token.NEQ, builtinCall("len", id(b))}
+		panicking := &ast.ExprStmt{builtinCall("panic", &ast.BasicLit{nopos, token.STRING, []byte(`"dotted operator on slices of different lengths"`)})}
+		body = []ast.Stmt{&ast.IfStmt{nopos, nil, differ, &ast.BlockStmt{nopos, []ast.Stmt{panicking}, nopos}, nil}}
+	}
+	body = appendStmts(body,
+		&ast.AssignStmt{[]ast.Expr{id(c)}, nopos, token.DEFINE, []ast.Expr{builtinCall("make", transform.Copy(result).(ast.Expr), builtinCall("len", id(length)))}},
+		&ast.RangeStmt{nopos, id(i), nil, nopos, token.DEFINE, id(c), &ast.BlockStmt{nopos, []ast.Stmt{
+			&ast.AssignStmt{[]ast.Expr{&ast.IndexExpr{id(c), id(i)}}, nopos, token.ASSIGN, []ast.Expr{
+				&ast.BinaryExpr{elem(a, lslice), nopos, op, elem(b, rslice)},
+			}},
+		}, nopos}},
+		&ast.ReturnStmt{nopos, []ast.Expr{id(c)}})
+	params := &ast.FieldList{nopos, []*ast.Field{
+		&ast.Field{nil, []*ast.Ident{id(a)}, lt, nil, nil},
+		&ast.Field{nil, []*ast.Ident{id(b)}, rt, nil, nil},
+	}, nopos}
+	results := &ast.FieldList{nopos, []*ast.Field{&ast.Field{nil, nil, result, nil, nil}}, nopos}
+	fn := &ast.FuncLit{&ast.FuncType{nopos, params, results}, &ast.BlockStmt{nopos, body, nopos}}
+	return &ast.CallExpr{fn, nopos, []ast.Expr{left, right}, nopos, nopos}
+}
+
+// builtinCall returns a call to the predeclared function name.
+func builtinCall(name string, args ...ast.Expr) *ast.CallExpr {
+	var nopos token.Position
+	return &ast.CallExpr{ast.NewIdent(name), nopos, args, nopos, nopos}
+}
+
+// appendStmts returns list followed by stmts.
+func appendStmts(list []ast.Stmt, stmts ...ast.Stmt) []ast.Stmt {
+	out := make([]ast.Stmt, len(list)+len(stmts))
+	copy(out, list)
+	copy(out[len(list):], stmts)
+	return out
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/printer"
+	"go/token"
+	"os"
+	"github.com/droundy/go-crazy/parser"
+	"github.com/droundy/go-crazy/scanner"
+	"github.com/droundy/go-crazy/transform"
+)
+
+// dottedTokens are the operators that may be dotted to call a method.
+var dottedTokens = []token.Token{token.ADD, token.ADD_ASSIGN, token.SUB, token.SUB_ASSIGN,
+	token.MUL, token.MUL_ASSIGN, token.QUO, token.QUO_ASSIGN}
+
+// operatorName returns the operator whose method is named name, such
+// as ".+" for _dot_add.
+func operatorName(name string) string {
+	if name == "_mul_dot" {
+		return "*."
+	}
+	for _, tok := range dottedTokens {
+		if parser.MungeOperator(tok) == name {
+			return "." + tok.String()
+		}
+	}
+	return name
+}
+
+// operatorMethod returns the name of the method called by the dotted
+// operator op, or "" if op is not one.
+func operatorMethod(op string) string {
+	if op == "*." {
+		return "_mul_dot"
+	}
+	for _, tok := range dottedTokens {
+		if "."+tok.String() == op {
+			return parser.MungeOperator(tok)
+		}
+	}
+	return ""
+}
+
+// An overload is one of the methods a type declares for an operator.
+type overload struct {
+	decl  *ast.FuncDecl
+	param ast.Expr // the type of its operand
+}
+
+// OverloadOperators lets a type declare an operator method more than
+// once, for operands of different types.  Each such method is renamed
+// after the type of its operand, as in _dot_mul_float64, and each call
+// to the operator is renamed after the method chosen by the type of
+// its operand, as found by transform.InferTypes.  Operators that a
+// type declares only once keep their names.  A call whose operand
+// matches no method or several, or whose receiver or operand type is
+// unknown, is reported as an error, as are the uses of an overloaded
+// operator other than calls, such as the method expression
+// Vec.(.*), and interfaces declaring it, which no type overloading it
+// could satisfy.
+func OverloadOperators(pkg *ast.Package) (*ast.Package, os.Error) {
+	errs := new(transform.Errors)
+	overloads := operatorMethods(pkg)
+	overloaded := make(map[string]bool) // the operator methods overloaded by some type
+	for recv, methods := range overloads {
+		for name, list := range methods {
+			if len(list) == 1 {
+				continue
+			}
+			overloaded[name] = true
+			mangled := make(map[string]bool)
+			for _, o := range list {
+				suffix := typeSuffix(o.param)
+				if suffix == "" {
+					errs.Errorf(o.param.Pos(), "cannot overload %s on an operand of this type", operatorName(name))
+				} else if mangled[suffix] {
+					errs.Errorf(o.decl.Pos(), "%s declared twice for %s with a %s operand", operatorName(name), recv, typeString(o.param))
+				}
+				mangled[suffix] = true
+				o.decl.Name.Name = name + "_" + suffix
+			}
+		}
+	}
+	if len(overloaded) == 0 {
+		return pkg, errs.GetError(scanner.Sorted)
+	}
+
+	types := transform.InferTypes(pkg)
+	// receiver returns the name of the type of x, or of the type x
+	// names, as in a method expression, or "" if it is not known.
+	receiver := func(x ast.Expr) string {
+		if typ := types.TypeOf(x); typ != nil {
+			return typeString(derefType(typ))
+		}
+		// a method expression, as in Vec.(.*) or (*Vec).(.*)
+		if p, ok := x.(*ast.ParenExpr); ok {
+			x = derefType(p.X)
+		}
+		if id, ok := x.(*ast.Ident); ok && overloads[id.Name] != nil {
+			return id.Name
+		}
+		return ""
+	}
+	post := func(c *transform.Cursor) bool {
+		switch n := c.Node().(type) {
+		case *ast.InterfaceType:
+			for _, m := range n.Methods.List {
+				for _, id := range m.Names {
+					if overloaded[id.Name] {
+						errs.Errorf(id.Pos(), "cannot declare the overloaded operator %s in an interface", operatorName(id.Name))
+					}
+				}
+			}
+
+		case *ast.SelectorExpr:
+			if call, ok := c.Parent().(*ast.CallExpr); ok && c.Name() == "Fun" && len(call.Args) == 1 || !overloaded[n.Sel.Name] {
+				return true
+			}
+			op := operatorName(n.Sel.Name)
+			recv := receiver(n.X)
+			if recv == "" {
+				errs.Errorf(n.Sel.Pos(), "cannot use the overloaded operator %s: the type of its receiver is unknown", op)
+			} else if len(overloads[recv][n.Sel.Name]) > 1 {
+				errs.Errorf(n.Sel.Pos(), "cannot use %s on %s other than by applying it, since it is overloaded", op, recv)
+			}
+
+		case *ast.CallExpr:
+			if len(n.Args) != 1 {
+				return true
+			}
+			sel, ok := n.Fun.(*ast.SelectorExpr)
+			if !ok || !overloaded[sel.Sel.Name] {
+				return true
+			}
+			op := operatorName(sel.Sel.Name)
+			typ := types.TypeOf(sel.X)
+			if typ == nil {
+				errs.Errorf(sel.Sel.Pos(), "cannot choose a method for %s: the type of its receiver is unknown", op)
+				return true
+			}
+			recv, ok := derefType(typ).(*ast.Ident)
+			if !ok {
+				// perhaps a type of another package
+				return true
+			}
+			list := overloads[recv.Name][sel.Sel.Name]
+			if len(list) < 2 {
+				return true
+			}
+			chosen, err := chooseOverload(types, list, n.Args[0])
+			if err != "" {
+				errs.Errorf(sel.Sel.Pos(), "cannot choose a method for %s on %s: %s", op, recv.Name, err)
+			} else {
+				sel.Sel.Name = chosen.decl.Name.Name
+			}
+		}
+		return true
+	}
+	pkg = transform.Apply(pkg, nil, post).(*ast.Package)
+	return pkg, errs.GetError(scanner.Sorted)
+}
+
+// operatorMethods returns the operator methods declared in pkg, by
+// the name of their receiver type and then by name, with more than
+// one for a name that is overloaded.
+func operatorMethods(pkg *ast.Package) map[string]map[string][]*overload {
+	methods := make(map[string]map[string][]*overload)
+	for _, f := range pkg.Files {
+		for _, d := range f.Decls {
+			d, ok := d.(*ast.FuncDecl)
+			if !ok || d.Recv == nil || !isOperatorMethod(d.Name.Name) || len(d.Type.Params.List) != 1 {
+				continue
+			}
+			recv, ok := derefType(d.Recv.List[0].Type).(*ast.Ident)
+			if !ok {
+				continue
+			}
+			if methods[recv.Name] == nil {
+				methods[recv.Name] = make(map[string][]*overload)
+			}
+			o := &overload{d, d.Type.Params.List[0].Type}
+			methods[recv.Name][d.Name.Name] = appendOverload(methods[recv.Name][d.Name.Name], o)
+		}
+	}
+	return methods
+}
+
+// chooseOverload returns the one of list whose operand type arg
+// matches, or else the reason why there isn't one.  An operand
+// matches an overload whose type is identical to its own, or failing
+// that one whose type is an interface.  An untyped constant matches
+// any overload taking a number, and prefers its own default type.
+func chooseOverload(types *transform.Types, list []*overload, arg ast.Expr) (*overload, string) {
+	typ := types.TypeOf(arg)
+	if typ == nil {
+		return nil, "the type of its operand is unknown"
+	}
+	var exact, loose []*overload
+	for _, o := range list {
+		switch {
+		case transform.Equal(o.param, typ, 0):
+			exact = appendOverload(exact, o)
+		case types.Untyped(arg) && types.IsNumeric(o.param):
+			loose = appendOverload(loose, o)
+		case isInterface(types, o.param):
+			loose = appendOverload(loose, o)
+		}
+	}
+	if len(exact) == 0 {
+		exact = loose
+	}
+	switch len(exact) {
+	case 0:
+		return nil, "no method takes an operand of type " + typeString(typ)
+	case 1:
+		return exact[0], ""
+	}
+	return nil, "an operand of type " + typeString(typ) + " matches both " + typeString(exact[0].param) + " and " + typeString(exact[1].param)
+}
+
+// typeString returns typ as it is written.
+func typeString(typ ast.Expr) string {
+	var buf bytes.Buffer
+	printer.Fprint(&buf, typ)
+	return buf.String()
+}
+
+func appendOverload(list []*overload, o *overload) []*overload {
+	l := make([]*overload, len(list)+1)
+	copy(l, list)
+	l[len(list)] = o
+	return l
+}
+
+func isInterface(types *transform.Types, typ ast.Expr) bool {
+	_, ok := types.Underlying(typ).(*ast.InterfaceType)
+	return ok
+}
+
+// typeSuffix returns the suffix naming typ in the name of an
+// overloaded operator method, or "" if it can't be named.
+func typeSuffix(typ ast.Expr) string {
+	switch t := typ.(type) {
+	case *ast.Ident:
+		return t.Name
+	case *ast.SelectorExpr:
+		if x := typeSuffix(t.X); x != "" {
+			return x + "_" + t.Sel.Name
+		}
+	case *ast.ParenExpr:
+		return typeSuffix(t.X)
+	case *ast.StarExpr:
+		if x := typeSuffix(t.X); x != "" {
+			return "ptr_" + x
+		}
+	case *ast.ArrayType:
+		elt := typeSuffix(t.Elt)
+		if elt == "" {
+			return ""
+		}
+		if t.Len == nil {
+			return "slice_" + elt
+		}
+		if l, ok := t.Len.(*ast.BasicLit); ok && l.Kind == token.INT {
+			return "array" + string(l.Value) + "_" + elt
+		}
+	case *ast.MapType:
+		if k, v := typeSuffix(t.Key), typeSuffix(t.Value); k != "" && v != "" {
+			return "map_" + k + "_" + v
+		}
+	}
+	return ""
+}
@@ -651,8 +651,14 @@ func (p *parser) parseMethodSpec() *ast.Field {
 	doc := p.leadComment
 	var idents []*ast.Ident
 	var typ ast.Expr
-	x := p.parseQualifiedIdent()
-	if ident, isIdent := x.(*ast.Ident); isIdent && p.tok == token.LPAREN {
+	var x ast.Expr
+	operator := isOperatorMethod(p.tok)
+	if operator {
+		x = p.parseOperatorName()
+	} else {
+		x = p.parseQualifiedIdent()
+	}
+	if ident, isIdent := x.(*ast.Ident); isIdent && (p.tok == token.LPAREN || operator) {
 		// method
 		idents = []*ast.Ident{ident}
 		params, results := p.parseSignature()
@@ -675,7 +681,7 @@ func (p *parser) parseInterfaceType() *ast.InterfaceType {
 	pos := p.expect(token.INTERFACE)
 	lbrace := p.expect(token.LBRACE)
 	var list vector.Vector
-	for p.tok == token.IDENT {
+	for p.tok == token.IDENT || isOperatorMethod(p.tok) {
 		list.Push(p.parseMethodSpec())
 	}
 	rbrace := p.expect(token.RBRACE)
@@ -858,7 +864,14 @@ func (p *parser) parseOperand() ast.Expr {
 		lparen := p.pos
 		p.next()
 		p.exprLev++
-		x := p.parseExpr()
+		var x ast.Expr
+		if isDotted(p.tok, p.lit) && p.tok.Precedence() > token.LowestPrec {
+			// a section missing its left operand, as in (.+ b)
+			x = p.parseBinaryOperands(&ast.Ident{p.pos, "_", nil}, token.LowestPrec+1, true)
+		} else {
+			// perhaps a section missing its right operand, as in (a .+)
+			x = p.parseBinaryOperands(p.parseUnaryExpr(), token.LowestPrec+1, true)
+		}
 		p.exprLev--
 		rparen := p.expect(token.RPAREN)
 		return &ast.ParenExpr{lparen, x, rparen}
@@ -891,8 +904,15 @@ func (p *parser) parseSelectorOrTypeAssertion(x ast.Expr) ast.Expr {
 		return &ast.SelectorExpr{x, sel}
 	}
 
-	// type assertion
 	p.expect(token.LPAREN)
+	if isDotted(p.tok, p.lit) {
+		// an operator method, as in Vec.(.+)
+		sel := p.parseOperatorName()
+		p.expect(token.RPAREN)
+		return &ast.SelectorExpr{x, sel}
+	}
+
+	// type assertion
 	var typ ast.Expr
 	if p.tok == token.TYPE {
 		// type switch: typ == nil
@@ -1197,16 +1217,32 @@ func (p *parser) parseBinaryExpr(prec1 int) ast.Expr {
 		defer un(trace(p, "BinaryExpr"))
 	}
 
-	x := p.parseUnaryExpr()
+	return p.parseBinaryOperands(p.parseUnaryExpr(), prec1, false)
+}
+
+
+// parseBinaryOperands parses the operators and operands following the
+// operand x of a binary expression.  If section is true, the
+// expression is the whole of a parenthesized one, and a dotted
+// operator followed by the closing parenthesis is a section missing
+// its right operand, as in (a .+), and is given the blank identifier
+// in its place.
+func (p *parser) parseBinaryOperands(x ast.Expr, prec1 int, section bool) ast.Expr {
 	for prec := p.tok.Precedence(); prec >= prec1; prec-- {
 		for p.tok.Precedence() == prec {
 			pos, op, oplit := p.pos, p.tok, p.lit
 			p.next()
 			var ellipsis token.Position
-			y := p.parseBinaryExpr(prec + 1)
+			var y ast.Expr
+			if section && isDotted(op, oplit) && p.tok == token.RPAREN {
+				y = &ast.Ident{p.pos, "_", nil}
+			} else {
+				y = p.parseBinaryExpr(prec + 1)
+			}
+			// the method name of a dotted operator lies at the operator
 			if oplit[0] == '.' {
 				x = &ast.CallExpr{
-					&ast.SelectorExpr{p.checkExpr(x), ast.NewIdent(MungeOperator(op))},
+					&ast.SelectorExpr{p.checkExpr(x), &ast.Ident{pos, MungeOperator(op), nil}},
 					pos,
 					[]ast.Expr{p.checkExpr(y)},
 					ellipsis,
@@ -1214,7 +1250,7 @@ func (p *parser) parseBinaryExpr(prec1 int) ast.Expr {
 				}
 			} else if string(oplit) == "*." {
 				x = &ast.CallExpr{
-					&ast.SelectorExpr{p.checkExpr(y), ast.NewIdent("_mul_dot")},
+					&ast.SelectorExpr{p.checkExpr(y), &ast.Ident{pos, "_mul_dot", nil}},
 					pos,
 					[]ast.Expr{p.checkExpr(x)},
 					ellipsis,
@@ -1269,9 +1305,24 @@ func (p *parser) parseSimpleStmt(labelOk bool) ast.Stmt {
 		token.REM_ASSIGN, token.AND_ASSIGN, token.OR_ASSIGN,
 		token.XOR_ASSIGN, token.SHL_ASSIGN, token.SHR_ASSIGN, token.AND_NOT_ASSIGN:
 		// assignment statement
-		pos, tok := p.pos, p.tok
+		pos, tok, lit := p.pos, p.tok, p.lit
 		p.next()
 		y := p.parseExprList()
+		if isDotted(tok, lit) {
+			// a dotted assignment calls the method of its left operand,
+			// as in a._dot_add_assign(b)
+			if len(x) != 1 || len(y) != 1 {
+				p.Error(pos, "dotted assignment needs one operand on each side")
+			}
+			var ellipsis token.Position
+			return &ast.ExprStmt{&ast.CallExpr{
+				&ast.SelectorExpr{x[0], &ast.Ident{pos, MungeOperator(tok), nil}},
+				pos,
+				[]ast.Expr{y[0]},
+				ellipsis,
+				p.pos,
+			}}
+		}
 		return &ast.AssignStmt{x, pos, tok, y}
 	}
 
@@ -1854,6 +1905,34 @@ func MungeOperator(tok token.Token) string {
 	return "bug here!"
 }
 
+// isOperatorMethod returns true if tok is an operator that may name a
+// method, as in func (a T) .+ (b T) T.
+func isOperatorMethod(tok token.Token) bool {
+	switch tok {
+	case token.ADD, token.ADD_ASSIGN, token.SUB, token.SUB_ASSIGN, token.MUL, token.MUL_ASSIGN, token.QUO, token.QUO_ASSIGN:
+		return true
+	}
+	return false
+}
+
+// isDotted returns true if tok, written as lit, is a dotted operator
+// naming a method, such as .+ or *.
+func isDotted(tok token.Token, lit []byte) bool {
+	return isOperatorMethod(tok) && len(lit) > 0 && (lit[0] == '.' || string(lit) == "*.")
+}
+
+// parseOperatorName parses an operator naming a method, in a method
+// declaration or an interface, and returns the name of the method the
+// operator calls.
+func (p *parser) parseOperatorName() *ast.Ident {
+	ident := &ast.Ident{ p.pos, MungeOperator(p.tok), nil }
+	if string(p.lit) == "*." {
+		ident.Name = "_mul_dot"
+	}
+	p.expect(p.tok) // hokey!
+	return ident
+}
+
 func (p *parser) parseFuncDecl() *ast.FuncDecl {
 	if p.trace {
 		defer un(trace(p, "FunctionDecl"))
@@ -1867,16 +1946,10 @@ func (p *parser) parseFuncDecl() *ast.FuncDecl {
 		recv = p.parseReceiver()
 	}
 
-	ident := &ast.Ident{ p.pos, "", nil }
-	switch p.tok {
-	case token.ADD, token.ADD_ASSIGN, token.SUB, token.SUB_ASSIGN, token.MUL, token.MUL_ASSIGN, token.QUO, token.QUO_ASSIGN:
-		if string(p.lit) == "*." {
-			ident.Name = "_mul_dot"
-		} else {
-			ident.Name = MungeOperator(p.tok)
-		}
-		p.expect(p.tok) // hokey!
-	default:
+	var ident *ast.Ident
+	if isOperatorMethod(p.tok) {
+		ident = p.parseOperatorName()
+	} else {
 		ident = p.parseIdent()
 	}
 	params, results := p.parseSignature()
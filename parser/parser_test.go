@@ -5,7 +5,6 @@
 package parser
 
 import (
-	"go/ast"
 	"os"
 	"testing"
 )
@@ -21,7 +20,7 @@ var illegalInputs = []interface{}{
 
 func TestParseIllegalInputs(t *testing.T) {
 	for _, src := range illegalInputs {
-		_, err := ParseFile("", src, nil, 0)
+		_, err := ParseFile("", src, 0)
 		if err == nil {
 			t.Errorf("ParseFile(%v) should have failed", src)
 		}
@@ -41,12 +40,13 @@ var validPrograms = []interface{}{
 	`package main; type T []int; var a []bool; func f() { if a[T{42}[0]] {} }` + "\n",
 	`package main; type T []int; func g(int) bool { return true }; func f() { if g(T{42}[0]) {} }` + "\n",
 	`package main; type T []int; func f() { for _ = range []int{T{42}[0]} {} }` + "\n",
+	`package main; func f() { _ = (a .+); _ = (.+ b); _ = Vec.(.+) }` + "\n",
 }
 
 
 func TestParseValidPrograms(t *testing.T) {
 	for _, src := range validPrograms {
-		_, err := ParseFile("", src, ast.NewScope(nil), 0)
+		_, err := ParseFile("", src, 0)
 		if err != nil {
 			t.Errorf("ParseFile(%q): %v", src, err)
 		}
@@ -54,6 +54,24 @@ func TestParseValidPrograms(t *testing.T) {
 }
 
 
+var invalidPrograms = []interface{}{
+	// a section must be the whole of a parenthesized expression
+	`package main; func main() { f(a .+) }` + "\n",
+	`package main; func main() { _ = a[b .+] }` + "\n",
+	`package main; func main() { _ = (a + b .*) }` + "\n",
+}
+
+
+func TestParseInvalidPrograms(t *testing.T) {
+	for _, src := range invalidPrograms {
+		_, err := ParseFile("", src, 0)
+		if err == nil {
+			t.Errorf("ParseFile(%q) should have failed", src)
+		}
+	}
+}
+
+
 var validFiles = []string{
 	"parser.go",
 	"parser_test.go",
@@ -62,7 +80,7 @@ var validFiles = []string{
 
 func TestParse3(t *testing.T) {
 	for _, filename := range validFiles {
-		_, err := ParseFile(filename, nil, ast.NewScope(nil), 0)
+		_, err := ParseFile(filename, nil, 0)
 		if err != nil {
 			t.Errorf("ParseFile(%s): %v", filename, err)
 		}
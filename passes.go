@@ -0,0 +1,292 @@
+package main
+
+import (
+	"container/vector"
+	"fmt"
+	"go/ast"
+	"go/printer"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"github.com/droundy/go-crazy/transform"
+)
+
+// A Pass is a named transformation of a package.
+type Pass struct {
+	Name     string
+	Help     string
+	Requires []string // the passes that must run before this one
+	Run      func(pkg *ast.Package, st *PassState) (*ast.Package, os.Error)
+}
+
+// A PassState holds what the passes run on a package share, other
+// than the package itself.
+type PassState struct {
+	Exports  []*Export
+	Report   *InlineReport
+	Bindings []*OperatorBinding    // the operators bound with --bind-operator
+	Origins  transform.Origins     // where the code generated by the passes came from
+	Comments *transform.CommentMap // the comments of the package, moved along with its code
+}
+
+// A PassError is an error returned by a pass, which may be a
+// scanner.ErrorList holding the errors found at various positions.
+type PassError struct {
+	Pass string
+	Err  os.Error
+}
+
+func (e *PassError) String() string { return e.Pass + ": " + e.Err.String() }
+
+var passes = make(map[string]*Pass)
+var passOrder vector.StringVector // the names of the passes, in the order registered
+
+// RegisterPass makes p available to SchedulePasses.  Passes are run
+// in the order registered unless their order is given explicitly.
+func RegisterPass(p *Pass) {
+	if _, ok := passes[p.Name]; ok {
+		panic("pass registered twice: " + p.Name)
+	}
+	passes[p.Name] = p
+	passOrder.Push(p.Name)
+}
+
+// SchedulePasses returns the named passes in the order given, each
+// preceded by the passes it requires, if they are not already run.
+func SchedulePasses(names []string) ([]*Pass, os.Error) {
+	var list vector.Vector
+	scheduled := make(map[string]bool)
+	visiting := make(map[string]bool)
+	var add func(name string) os.Error
+	add = func(name string) os.Error {
+		if scheduled[name] {
+			return nil
+		}
+		p, ok := passes[name]
+		if !ok {
+			return os.NewError("unknown pass: " + name)
+		}
+		if visiting[name] {
+			return os.NewError("pass requires itself: " + name)
+		}
+		visiting[name] = true
+		for _, r := range p.Requires {
+			if err := add(r); err != nil {
+				return err
+			}
+		}
+		scheduled[name] = true
+		list.Push(p)
+		return nil
+	}
+	for _, name := range names {
+		if err := add(name); err != nil {
+			return nil, err
+		}
+	}
+	out := make([]*Pass, list.Len())
+	for i, p := range list {
+		out[i] = p.(*Pass)
+	}
+	return out, nil
+}
+
+// RunPasses runs each pass in list on pkg.  The program is printed
+// to os.Stdout after each pass named in dumpAfter, and if times is
+// not nil, the time taken by each pass is written to it.
+func RunPasses(pkg *ast.Package, list []*Pass, st *PassState, dumpAfter map[string]bool, times io.Writer) (*ast.Package, os.Error) {
+	for _, p := range list {
+		start := time.Nanoseconds()
+		out, err := runPass(p, pkg, st)
+		if err != nil {
+			return pkg, &PassError{p.Name, err}
+		}
+		pkg = out
+		st.Comments.Update(pkg)
+		if times != nil {
+			fmt.Fprintf(times, "pass %s: %.3fms\n", p.Name, float64(time.Nanoseconds()-start)/1e6)
+		}
+		if dumpAfter[p.Name] {
+			if err := dumpPackage(os.Stdout, pkg, "after "+p.Name); err != nil {
+				return pkg, err
+			}
+		}
+	}
+	return pkg, nil
+}
+
+// runPass runs p on pkg, returning an error rather than panicking if
+// the pass meets a node of a type that the transforms don't know.
+func runPass(p *Pass, pkg *ast.Package, st *PassState) (out *ast.Package, err os.Error) {
+	defer transform.CatchUnknownNode(&err)
+	return p.Run(pkg, st)
+}
+
+// dumpPackage prints each file of pkg to w, headed by a comment
+// naming the file.
+func dumpPackage(w io.Writer, pkg *ast.Package, when string) os.Error {
+	names := make([]string, len(pkg.Files))
+	i := 0
+	for name := range pkg.Files {
+		names[i] = name
+		i++
+	}
+	sort.SortStrings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "// %s: %s\n", when, name)
+		if err := printer.Fprint(w, pkg.Files[name]); err != nil {
+			return err
+		}
+		fmt.Fprintln(w)
+	}
+	return nil
+}
+
+// splitList splits a comma-separated list, ignoring empty elements.
+func splitList(s string) []string {
+	var list vector.StringVector
+	for s != "" {
+		comma := strings.Index(s, ",")
+		if comma < 0 {
+			comma = len(s)
+		}
+		if name := strings.TrimSpace(s[0:comma]); name != "" {
+			list.Push(name)
+		}
+		if comma < len(s) {
+			comma++
+		}
+		s = s[comma:]
+	}
+	return list
+}
+
+// overloaded are the requirements of the passes that must know which
+// operator method each call chooses.
+var overloaded = []string{"overload-operators"}
+
+func init() {
+	RegisterPass(&Pass{"rewrite", "apply the rules given with -r", nil, rewritePass})
+	RegisterPass(&Pass{"operator-sections", "turn sections of dotted operators into functions", nil, sectionsPass})
+	RegisterPass(&Pass{"compound-operators", "supply the dotted operators and dotted assignments a type lacks from the others", []string{"operator-sections"}, compoundPass})
+	RegisterPass(&Pass{"overload-operators", "choose among the operator methods overloaded by operand type", []string{"compound-operators"}, overloadPass})
+	RegisterPass(&Pass{"bind-operators", "make dotted operators call the methods named with --bind-operator", []string{"compound-operators"}, bindPass})
+	RegisterPass(&Pass{"inline", "inline the functions named with --inline", overloaded, inlinePass})
+	RegisterPass(&Pass{"inline-auto", "inline small leaf functions and operator methods", overloaded, autoInlinePass})
+	RegisterPass(&Pass{"lower-operators", "turn dotted operators on numbers and slices of numbers into ordinary operators and loops", overloaded, lowerPass})
+	RegisterPass(&Pass{"unused-imports", "remove the imports left unused by the other passes", nil, unusedImportsPass})
+	// the packages registering plugins are initialized before this one
+	for _, p := range transform.Plugins() {
+		RegisterPass(&Pass{p.Name, p.Description, p.After, pluginPass(p)})
+	}
+}
+
+// pluginPass returns a pass walking the package with a new visitor
+// from p.
+func pluginPass(p *transform.Plugin) func(pkg *ast.Package, st *PassState) (*ast.Package, os.Error) {
+	return func(pkg *ast.Package, st *PassState) (*ast.Package, os.Error) {
+		return transform.Walk(p.New(), pkg).(*ast.Package), nil
+	}
+}
+
+func sectionsPass(pkg *ast.Package, st *PassState) (*ast.Package, os.Error) {
+	return MakeSections(pkg, st)
+}
+
+func compoundPass(pkg *ast.Package, st *PassState) (*ast.Package, os.Error) {
+	return CompoundOperators(pkg, st)
+}
+
+func overloadPass(pkg *ast.Package, st *PassState) (*ast.Package, os.Error) {
+	return OverloadOperators(pkg)
+}
+
+func lowerPass(pkg *ast.Package, st *PassState) (*ast.Package, os.Error) {
+	return LowerOperators(pkg, st), nil
+}
+
+func unusedImportsPass(pkg *ast.Package, st *PassState) (*ast.Package, os.Error) {
+	for _, f := range pkg.Files {
+		transform.DeleteUnusedImports(f)
+	}
+	return pkg, nil
+}
+
+// ListPasses writes a line to w for each pass registered, naming it
+// and describing what it does, what it must follow and which syntax
+// extensions it relies on.
+func ListPasses(w io.Writer) {
+	width := 0
+	for _, name := range passOrder {
+		if len(name) > width {
+			width = len(name)
+		}
+	}
+	for _, name := range passOrder {
+		p := passes[name]
+		line := name + strings.Repeat(" ", width-len(name)) + "  " + p.Help
+		if len(p.Requires) > 0 {
+			line += " (after " + strings.Join(p.Requires, ", ") + ")"
+		}
+		if plugin := transform.LookupPlugin(name); plugin != nil && len(plugin.Extensions) > 0 {
+			line += " [uses " + strings.Join(plugin.Extensions, ", ") + "]"
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+func rewritePass(pkg *ast.Package, st *PassState) (*ast.Package, os.Error) {
+	if len(*rewrites) == 0 {
+		return pkg, nil
+	}
+	rules := make([]*transform.Rule, len(*rewrites))
+	for i, r := range *rewrites {
+		rule, err := transform.ParseRule(r)
+		if err != nil {
+			return pkg, err
+		}
+		rules[i] = rule
+	}
+	return transform.Rewrite(pkg, rules, st.Origins).(*ast.Package), nil
+}
+
+func bindPass(pkg *ast.Package, st *PassState) (*ast.Package, os.Error) {
+	if len(st.Bindings) == 0 {
+		return pkg, nil
+	}
+	return BindOperators(pkg, st.Bindings, st)
+}
+
+func inlinePass(pkg *ast.Package, st *PassState) (*ast.Package, os.Error) {
+	for _, fname := range *toinline {
+		var err os.Error
+		if dot := strings.Index(fname, "."); dot >= 0 {
+			found := false
+			for _, exp := range st.Exports {
+				if exp.Name() == fname[0:dot] && err == nil {
+					found = true
+					pkg, err = InlineImported(pkg, exp, fname[dot+1:], *inlinedepth, st)
+				}
+			}
+			if !found {
+				err = os.NewError("no inlinable function " + fname)
+			}
+		} else {
+			pkg, err = Inline(pkg, fname, *inlinedepth, st)
+		}
+		if err != nil {
+			return pkg, err
+		}
+	}
+	return pkg, nil
+}
+
+func autoInlinePass(pkg *ast.Package, st *PassState) (*ast.Package, os.Error) {
+	pkg, decisions, err := InlineAuto(pkg, *inlinebudget, *inlinedepth, st)
+	for _, d := range decisions {
+		st.Report.Decide(d)
+	}
+	return pkg, err
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"container/vector"
+	"go/ast"
+	"github.com/droundy/go-crazy/transform"
+)
+
+// A callGraph maps each function declaration to the declarations of
+// the functions it may call.  Methods are matched by name alone, so
+// a call to a method may lead to any method of the same name.
+type callGraph map[*ast.FuncDecl]map[*ast.FuncDecl]bool
+
+// newCallGraph returns the call graph of the functions declared in
+// pkg and recorded in exports.
+func newCallGraph(pkg *ast.Package, exports []*Export) callGraph {
+	g := make(callGraph)
+	v := callCollector{make(map[string]*ast.FuncDecl), make(map[string]*vector.Vector), "", nil}
+	var decls vector.Vector
+	for _, f := range pkg.Files {
+		for _, d := range f.Decls {
+			if d, ok := d.(*ast.FuncDecl); ok {
+				v.add(d)
+				decls.Push(d)
+			}
+		}
+	}
+	for _, d := range decls {
+		d := d.(*ast.FuncDecl)
+		v.callees = make(map[*ast.FuncDecl]bool)
+		if d.Body != nil {
+			transform.Walk(&v, d.Body)
+		}
+		g[d] = v.callees
+	}
+
+	for _, exp := range exports {
+		// an export's functions refer to each other by qualified name
+		e := callCollector{make(map[string]*ast.FuncDecl), make(map[string]*vector.Vector), exp.Name(), nil}
+		for _, d := range exp.File.Decls {
+			if d, ok := d.(*ast.FuncDecl); ok {
+				e.add(d)
+			}
+		}
+		for _, d := range exp.File.Decls {
+			if d, ok := d.(*ast.FuncDecl); ok {
+				e.callees = make(map[*ast.FuncDecl]bool)
+				if d.Body != nil {
+					transform.Walk(&e, d.Body)
+				}
+				g[d] = e.callees
+			}
+		}
+	}
+	return g
+}
+
+// recursive returns true if decl may call itself, either directly or
+// through other functions.
+func (g callGraph) recursive(decl *ast.FuncDecl) bool {
+	return g.reaches(decl, decl, make(map[*ast.FuncDecl]bool))
+}
+
+func (g callGraph) reaches(from, to *ast.FuncDecl, seen map[*ast.FuncDecl]bool) bool {
+	for callee := range g[from] {
+		if callee == to {
+			return true
+		}
+		if !seen[callee] {
+			seen[callee] = true
+			if g.reaches(callee, to, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// A callCollector finds the functions called within a body.  If
+// qualifier is not empty, functions are called as qualifier.Name.
+type callCollector struct {
+	funcs     map[string]*ast.FuncDecl
+	methods   map[string]*vector.Vector
+	qualifier string
+	callees   map[*ast.FuncDecl]bool
+}
+
+func (v *callCollector) add(d *ast.FuncDecl) {
+	if d.Recv == nil {
+		v.funcs[d.Name.Name] = d
+		return
+	}
+	list, ok := v.methods[d.Name.Name]
+	if !ok {
+		list = new(vector.Vector)
+		v.methods[d.Name.Name] = list
+	}
+	list.Push(d)
+}
+
+func (v *callCollector) Visit(node interface{}) interface{} {
+	n, ok := node.(*ast.CallExpr)
+	if !ok {
+		return nil
+	}
+	switch f := n.Fun.(type) {
+	case *ast.Ident:
+		if d, ok := v.funcs[f.Name]; ok && v.qualifier == "" {
+			v.callees[d] = true
+		}
+	case *ast.SelectorExpr:
+		if x, ok := f.X.(*ast.Ident); ok && v.qualifier != "" && x.Name == v.qualifier {
+			if d, ok := v.funcs[f.Sel.Name]; ok {
+				v.callees[d] = true
+			}
+		} else if list, ok := v.methods[f.Sel.Name]; ok {
+			for _, d := range *list {
+				v.callees[d.(*ast.FuncDecl)] = true
+			}
+		}
+	}
+	return nil
+}
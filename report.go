@@ -0,0 +1,154 @@
+package main
+
+import (
+	"container/vector"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io"
+	"json"
+	"os"
+	"github.com/droundy/go-crazy/transform"
+)
+
+// An InlineSite records what became of a call to a function that an
+// Inliner was given.
+type InlineSite struct {
+	Pos      token.Position // the position of the call in the source
+	Callee   string
+	Inlined  bool
+	Strategy string // how the call was inlined, as a "closure"
+	Reason   string // why the call was not inlined
+	Growth   int    // the estimated change in size, in AST nodes
+}
+
+func (s *InlineSite) String() string {
+	if s.Inlined {
+		return fmt.Sprintf("%s: call to %s: inlined as %s (%+d nodes)", s.Pos, s.Callee, s.Strategy, s.Growth)
+	}
+	return fmt.Sprintf("%s: call to %s: not inlined: %s", s.Pos, s.Callee, s.Reason)
+}
+
+// An InlineReport collects the InlineSites of every call considered
+// for inlining, and the InlineDecisions of InlineAuto.  A nil
+// *InlineReport silently discards them.
+type InlineReport struct {
+	decisions vector.Vector
+	sites     vector.Vector
+}
+
+func (r *InlineReport) Add(s *InlineSite) {
+	if r != nil {
+		r.sites.Push(s)
+	}
+}
+
+// Decide records the decision InlineAuto made about a function.
+func (r *InlineReport) Decide(d *InlineDecision) {
+	if r != nil {
+		r.decisions.Push(d)
+	}
+}
+
+func (r *InlineReport) Len() int { return r.sites.Len() }
+
+func (r *InlineReport) At(i int) *InlineSite { return r.sites.At(i).(*InlineSite) }
+
+// Write writes the report to w, one decision or call site per line,
+// with the decisions first.
+func (r *InlineReport) Write(w io.Writer) os.Error {
+	for _, d := range r.decisions {
+		if _, err := fmt.Fprintln(w, d); err != nil {
+			return err
+		}
+	}
+	for i := 0; i < r.Len(); i++ {
+		if _, err := fmt.Fprintln(w, r.At(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// A jsonSite is an InlineSite as it is written in a JSON report.
+type jsonSite struct {
+	File     string "file"
+	Line     int    "line"
+	Column   int    "column"
+	Callee   string "callee"
+	Inlined  bool   "inlined"
+	Strategy string "strategy"
+	Reason   string "reason"
+	Growth   int    "growth"
+}
+
+// A jsonDecision is an InlineDecision as it is written in a JSON
+// report.
+type jsonDecision struct {
+	File     string "file"
+	Line     int    "line"
+	Column   int    "column"
+	Function string "function"
+	Inlined  bool   "inlined"
+	Cost     int    "cost"
+	Reason   string "reason"
+}
+
+// A jsonReport is an InlineReport as it is written in JSON.
+type jsonReport struct {
+	Functions []jsonDecision "functions"
+	Calls     []jsonSite     "calls"
+}
+
+// WriteJSON writes the report to w as a JSON object, holding an array
+// of the decisions, one per function, under "functions", and an array
+// of the call sites under "calls".
+func (r *InlineReport) WriteJSON(w io.Writer) os.Error {
+	report := jsonReport{make([]jsonDecision, r.decisions.Len()), make([]jsonSite, r.Len())}
+	for i, x := range r.decisions {
+		d := x.(*InlineDecision)
+		pos := d.Decl.Pos()
+		report.Functions[i] = jsonDecision{pos.Filename, pos.Line, pos.Column, d.Name(), d.Inline, d.Cost, d.Reason}
+	}
+	for i := range report.Calls {
+		s := r.At(i)
+		report.Calls[i] = jsonSite{s.Pos.Filename, s.Pos.Line, s.Pos.Column, s.Callee, s.Inlined, s.Strategy, s.Reason, s.Growth}
+	}
+	b, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(b); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w)
+	return err
+}
+
+// funcName returns the name of decl, qualified by its receiver type
+// if it is a method, or by pkg if that is not empty.
+func funcName(decl *ast.FuncDecl, pkg string) string {
+	if decl.Recv != nil {
+		return exprString(derefType(decl.Recv.List[0].Type)) + "." + decl.Name.Name
+	}
+	if pkg != "" {
+		return pkg + "." + decl.Name.Name
+	}
+	return decl.Name.Name
+}
+
+// countNodes returns the number of AST nodes within node.
+func countNodes(node interface{}) int {
+	var c nodeCounter
+	transform.Walk(&c, node)
+	return int(c)
+}
+
+type nodeCounter int
+
+func (c *nodeCounter) Visit(node interface{}) interface{} {
+	if _, ok := node.(ast.Node); ok {
+		*c++
+	}
+	return nil
+}
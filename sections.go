@@ -0,0 +1,158 @@
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"os"
+	"github.com/droundy/go-crazy/scanner"
+	"github.com/droundy/go-crazy/transform"
+)
+
+// isHole returns true if x is the blank identifier standing for the
+// missing operand of a section.
+func isHole(x ast.Expr) bool {
+	id, ok := x.(*ast.Ident)
+	return ok && id.Name == "_"
+}
+
+// MakeSections replaces each section of a dotted operator in pkg, such
+// as (.+ b) or (a .+), which the parser gives the blank identifier in
+// place of its missing operand, by a function of that operand.  The
+// operand given is evaluated once, where the section is written:
+//
+//	func(b Vec) func(Vec) Vec {
+//		return func(a Vec) Vec { return a._dot_add(b) }
+//	}(v)
+//
+// The type of the operand given is found by transform.InferTypes.  A
+// missing argument takes the type of the method's parameter, and an
+// operand of an operator on numbers the type of the operand given.  A
+// missing receiver is taken to be of the type of the argument given
+// if that type declares the operator for an operand of its own type,
+// or if it is a number and no type declares the operator.  Sections
+// whose types can't be found, and sections missing the argument of an
+// operator that the type of their receiver overloads, are reported as
+// errors.  The positions of the code generated are recorded in
+// st.Origins.
+func MakeSections(pkg *ast.Package, st *PassState) (*ast.Package, os.Error) {
+	errs := new(transform.Errors)
+	types := transform.InferTypes(pkg)
+	methods := operatorMethods(pkg)
+	post := func(c *transform.Cursor) bool {
+		call, ok := c.Node().(*ast.CallExpr)
+		if !ok || len(call.Args) != 1 {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || !isOperatorMethod(sel.Sel.Name) || !isHole(sel.X) && !isHole(call.Args[0]) {
+			return true
+		}
+		op := operatorName(sel.Sel.Name)
+		if isHole(sel.X) && isHole(call.Args[0]) {
+			errs.Errorf(sel.Sel.Pos(), "the section of %s needs one of its operands", op)
+			return true
+		}
+		x, err := section(types, methods, call)
+		if err != "" {
+			errs.Errorf(sel.Sel.Pos(), "cannot make a section of %s: %s", op, err)
+			return true
+		}
+		transform.Synthesize(x, call, st.Origins)
+		c.Replace(x)
+		return true
+	}
+	pkg = transform.Apply(pkg, nil, post).(*ast.Package)
+	return pkg, errs.GetError(scanner.Sorted)
+}
+
+// section returns the function made of the section call, or else the
+// reason why it can't be made.  The operator methods declared are
+// given by methods, as returned by operatorMethods.
+func section(types *transform.Types, methods map[string]map[string][]*overload, call *ast.CallExpr) (ast.Expr, string) {
+	sel := call.Fun.(*ast.SelectorExpr)
+	name := sel.Sel.Name
+	holeRecv := isHole(sel.X)
+	given := sel.X
+	if holeRecv {
+		given = call.Args[0]
+	}
+	typ := types.TypeOf(given)
+	if typ == nil {
+		return nil, "the type of its operand is unknown"
+	}
+	hole, result := typ, typ
+	f := types.MethodType(typ, name)
+	if recv, ok := derefType(typ).(*ast.Ident); ok && len(methods[recv.Name][name]) > 1 {
+		if !holeRecv {
+			return nil, typeString(typ) + " overloads it"
+		}
+		// the method taking an operand of the type of the argument
+		f = nil
+		for _, o := range methods[recv.Name][name] {
+			if transform.Equal(o.param, typ, 0) {
+				f = o.decl.Type
+			}
+		}
+	}
+	switch {
+	case holeRecv && f != nil:
+		// the receiver is of the type of the argument only if its
+		// method takes an operand of that type
+		if len(f.Params.List) != 1 || !transform.Equal(f.Params.List[0].Type, typ, 0) {
+			return nil, "the type of its receiver is unknown"
+		}
+		if f.Results != nil && len(f.Results.List) == 1 {
+			result = f.Results.List[0].Type
+		}
+	case holeRecv:
+		if !types.IsNumeric(typ) && numericElem(types, typ, name) == nil || declaresOperator(methods, name) {
+			return nil, "the type of its receiver is unknown"
+		}
+	case f != nil:
+		if len(f.Params.List) != 1 || f.Results == nil || len(f.Results.List) != 1 {
+			return nil, "the method of " + typeString(typ) + " is not an operator"
+		}
+		hole, result = f.Params.List[0].Type, f.Results.List[0].Type
+	case !types.IsNumeric(typ) && numericElem(types, typ, name) == nil:
+		return nil, typeString(typ) + " has no method for it"
+	}
+
+	g := transform.NewGensym(typ, hole, result)
+	a, b := g.Name("a"), g.Name("b") // the receiver and its argument
+	bound, missing := a, b
+	if holeRecv {
+		bound, missing = b, a
+	}
+	id := ast.NewIdent
+	var nopos token.Position
+	fields := func(name string, typ ast.Expr) *ast.FieldList {
+		var names []*ast.Ident
+		if name != "" {
+			names = []*ast.Ident{id(name)}
+		}
+		typ = transform.Copy(typ).(ast.Expr)
+		return &ast.FieldList{nopos, []*ast.Field{&ast.Field{nil, names, typ, nil, nil}}, nopos}
+	}
+	apply := &ast.CallExpr{&ast.SelectorExpr{id(a), id(sel.Sel.Name)}, nopos, []ast.Expr{id(b)}, nopos, nopos}
+	fn := &ast.FuncLit{
+		&ast.FuncType{nopos, fields(missing, hole), fields("", result)},
+		&ast.BlockStmt{nopos, []ast.Stmt{&ast.ReturnStmt{nopos, []ast.Expr{apply}}}, nopos},
+	}
+	fnType := &ast.FuncType{nopos, fields("", hole), fields("", result)}
+	maker := &ast.FuncLit{
+		&ast.FuncType{nopos, fields(bound, typ), fields("", fnType)},
+		&ast.BlockStmt{nopos, []ast.Stmt{&ast.ReturnStmt{nopos, []ast.Expr{fn}}}, nopos},
+	}
+	return &ast.CallExpr{maker, nopos, []ast.Expr{given}, nopos, nopos}, ""
+}
+
+// declaresOperator returns true if any of methods, as returned by
+// operatorMethods, is the operator method name.
+func declaresOperator(methods map[string]map[string][]*overload, name string) bool {
+	for _, m := range methods {
+		if len(m[name]) > 0 {
+			return true
+		}
+	}
+	return false
+}
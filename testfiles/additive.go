@@ -0,0 +1,26 @@
+package main
+
+import "fmt"
+
+type Additive interface {
+	.+ (Additive) Additive
+}
+
+type Money int
+
+func (a Money) .+ (b Additive) Additive {
+	return a + b.(Money)
+}
+
+// sum adds up any Additive values
+func sum(xs []Additive) Additive {
+	total := xs[0]
+	for _, x := range xs[1:] {
+		total = total .+ x
+	}
+	return total
+}
+
+func main() {
+	fmt.Println(sum([]Additive{Money(1), Money(2), Money(3)}))
+}
@@ -0,0 +1,11 @@
+#!/bin/sh
+
+set -ev
+
+./additive | grep '^6$'
+
+# the interface declares the method that the operator calls
+grep '_dot_add(Additive) Additive' additive-compiled.go
+grep 'total._dot_add(x)' additive-compiled.go
+
+echo Interfaces may declare operators!
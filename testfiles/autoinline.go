@@ -0,0 +1,27 @@
+package main
+
+import "fmt"
+
+type Vec []float64
+
+func (a Vec) .+ (b Vec) Vec {
+	return Vec{ a[0]+b[0], a[1]+b[1] }
+}
+
+func square(x float64) float64 {
+	return x*x
+}
+
+// greet is not a leaf, so it is never inlined automatically.
+func greet() {
+	fmt.Println("Hello world!")
+}
+
+func main() {
+	x := Vec{1,2}
+	y := x .+ x .+ x
+	if y[1] != 6 || square(y[0]) != 9 {
+		panic("bug!")
+	}
+	greet()
+}
@@ -0,0 +1,33 @@
+#!/bin/sh
+
+set -ev
+
+./autoinline > noinline.temp
+
+# the decisions are only reported when asked for
+../go-crazy --inline-auto autoinline.go > quiet.temp
+test ! -s quiet.temp
+
+../go-crazy --inline-auto --inline-report autoinline.go > report.temp
+
+grep 'not inlining greet: not a leaf: calls fmt.Println' report.temp
+grep 'inlining Vec._dot_add' report.temp
+grep 'inlining square' report.temp
+
+grep '\._dot_add(' autoinline-compiled.go && exit 1
+grep 'square(y' autoinline-compiled.go && exit 1
+
+../go-crazy --just-translate --inline-auto --inline-budget 5 --inline-report autoinline.go | grep 'not inlining square: cost'
+
+../go-crazy --just-translate --inline-auto --inline-report --inline-report-json report.json autoinline.go > report.temp
+grep 'call to square: inlined as closure' report.temp
+grep 'call to Vec._dot_add: inlined as closure' report.temp
+grep 'call to greet: not inlined: not a leaf' report.temp
+grep '"callee":"square","inlined":true,"strategy":"closure"' report.json
+grep '"function":"greet","inlined":false' report.json
+
+./autoinline > inline.temp
+
+diff inline.temp noinline.temp
+
+echo Automatic inlining works!
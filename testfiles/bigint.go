@@ -0,0 +1,11 @@
+package main
+
+import (
+	"big"
+	"fmt"
+)
+
+func main() {
+	var x, y *big.Int = big.NewInt(6), big.NewInt(7)
+	fmt.Println(new(big.Int).Add(new(big.Int).Mul(x, y), x))
+}
@@ -0,0 +1,70 @@
+#!/bin/sh
+
+set -ev
+
+./bigint | grep '^48$'
+
+# the operators on *big.Int call its methods
+cat > bigops.go <<END
+package main
+
+import (
+	"big"
+	"fmt"
+)
+
+func main() {
+	var x, y *big.Int = big.NewInt(6), big.NewInt(7)
+	fmt.Println(x .* y .+ x)
+	z := y .- x
+	fmt.Println(z .* z .- x)
+}
+END
+../go-crazy --bind-operator '*big.Int .+ Add dest' --bind-operator '*big.Int .- Sub dest' \
+	--bind-operator '*big.Int .* Mul dest' bigops.go
+./bigops > bigops.temp
+cat > expected.temp <<END
+48
+-5
+END
+diff bigops.temp expected.temp
+grep 'new(big.Int).Add(new(big.Int).Mul(x, y), x)' bigops-compiled.go
+
+# so may those of any other type, under its own convention
+cat > recv.go <<END
+package main
+
+import "fmt"
+
+type Money struct{ Cents int }
+
+func (m Money) Plus(n Money) Money { return Money{m.Cents + n.Cents} }
+
+func main() {
+	fmt.Println(Money{1} .+ Money{2})
+}
+END
+../go-crazy --bind-operator 'Money .+ Plus' recv.go
+./recv | grep '^{3}$'
+
+# an in-place operator bound by "dest" names its receiver twice
+cat > bigtwice.go <<END
+package main
+
+import "big"
+
+func key() string { return "x" }
+
+func main() {
+	m := map[string]*big.Int{"x": big.NewInt(1)}
+	m[key()] .+= big.NewInt(2)
+}
+END
+../go-crazy --bind-operator '*big.Int .+= Add dest' --just-translate bigtwice.go > errors.temp && exit 1
+grep 'Error in pass bind-operators:' errors.temp
+grep 'bigtwice.go:9:11: cannot bind .+= to Add: its receiver would be evaluated twice' errors.temp
+
+../go-crazy --bind-operator 'big.Int .+ Add dest' bigops.go && exit 1
+../go-crazy --bind-operator '*big.Int + Add' bigops.go && exit 1
+
+echo Operators are bound to methods!
@@ -0,0 +1,25 @@
+package main
+
+import "fmt"
+
+var scale = 2
+
+func scaled(x int) int {
+	return x * scale
+}
+
+func report(x int) {
+	fmt.Println("got", x)
+}
+
+func main() {
+	fmt.Println(scaled(1))
+	{
+		scale := 10
+		fmt.Println(scaled(scale))
+	}
+	{
+		fmt := "%d"
+		report(len(fmt))
+	}
+}
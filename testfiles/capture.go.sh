@@ -0,0 +1,18 @@
+#!/bin/sh
+
+set -ev
+
+./capture > noinline.temp
+
+# the locals of main would capture the names the inlined bodies refer
+# to, so they are renamed
+../go-crazy --inline scaled --inline report capture.go
+grep 'scaled(' capture-compiled.go && exit 1
+grep 'scale_1 := 10' capture-compiled.go
+grep 'fmt_1 := "%d"' capture-compiled.go
+
+./capture > inline.temp
+
+diff inline.temp noinline.temp
+
+echo Inlined bodies keep their names!
@@ -0,0 +1,18 @@
+package main
+
+import "fmt"
+
+// sum adds up its arguments.
+func sum(xs ...int) int {
+	total := 0
+	// add them one at a time
+	for _, x := range xs {
+		total += x
+	}
+	return total // the sum
+}
+
+func main() {
+	// print six
+	fmt.Println(sum(1, 2, 3))
+}
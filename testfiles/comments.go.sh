@@ -0,0 +1,20 @@
+#!/bin/sh
+
+set -ev
+
+./comments > noinline.temp
+
+# the comments of the body go with it to the call, and the doc comment
+# stays with the declaration that replaces it
+../go-crazy --inline sum comments.go
+grep 'sum(1, 2, 3)' comments-compiled.go && exit 1
+test `grep -c 'add them one at a time' comments-compiled.go` = 1
+test `grep -c 'the sum' comments-compiled.go` = 1
+sed -n '/func main/,$p' comments-compiled.go | grep 'add them one at a time'
+sed -n '/func main/,$p' comments-compiled.go | grep 'the sum'
+grep -B1 'const' comments-compiled.go | grep 'sum adds up its arguments'
+
+./comments > inline.temp
+diff inline.temp noinline.temp
+
+echo Comments move with the code!
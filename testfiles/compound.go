@@ -0,0 +1,46 @@
+package main
+
+import "fmt"
+
+// an Acc has only the in-place operator
+type Acc struct {
+	n int
+}
+
+func (a *Acc) .+= (b int) { a.n += b }
+
+// a Vec has only the ordinary one
+type Vec []float64
+
+func (a Vec) .+ (b Vec) Vec {
+	c := make(Vec, len(a))
+	for i := range a {
+		c[i] = a[i] + b[i]
+	}
+	return c
+}
+
+// a Buf has only the in-place one, and shares its elements when copied
+type Buf []int
+
+func (a Buf) .+= (b Buf) {
+	for i := range a {
+		a[i] += b[i]
+	}
+}
+
+func main() {
+	var a Acc
+	a .+= 2
+	b := a .+ 3
+	fmt.Println(a.n, b.n)
+	v := Vec{1, 2}
+	v .+= Vec{10, 20}
+	fmt.Println(v)
+	x := Buf{1, 2}
+	y := x .+ Buf{1, 1}
+	fmt.Println(x, y)
+	f := 1.5
+	f .+= 1
+	fmt.Println(f)
+}
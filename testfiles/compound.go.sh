@@ -0,0 +1,54 @@
+#!/bin/sh
+
+set -ev
+
+./compound > compound.temp
+cat > expected.temp <<END
+2 5
+[11 22]
+[1 2] [2 3]
+2.5
+END
+diff compound.temp expected.temp
+
+# the in-place method is called where it is declared
+grep 'a._dot_add_assign(2)' compound-compiled.go
+grep 'v = v._dot_add(Vec{10, 20})' compound-compiled.go
+
+# a map can't be copied to apply an operator in place
+cat > counts.go <<END
+package main
+
+type Counts map[string]int
+
+func (c Counts) .+= (k string) { c[k]++ }
+
+func main() {
+	var c Counts
+	c = c .+ "x"
+}
+END
+../go-crazy --just-translate counts.go > errors.temp && exit 1
+grep 'Error in pass compound-operators:' errors.temp
+grep 'counts.go:9:8: cannot apply .+= to a copy of a Counts' errors.temp
+
+# nor can a left operand with side effects be evaluated twice
+cat > twice.go <<END
+package main
+
+type Vec []float64
+
+func (a Vec) .+ (b Vec) Vec { return a }
+
+func next() int { return 0 }
+
+func main() {
+	vs := []Vec{Vec{1}}
+	vs[next()] .+= Vec{2}
+}
+END
+../go-crazy --just-translate twice.go > errors.temp && exit 1
+grep 'Error in pass compound-operators:' errors.temp
+grep 'twice.go:11:13: cannot make .+= of .+: its left operand would be evaluated twice' errors.temp
+
+echo Compound operators are synthesized!
@@ -0,0 +1,14 @@
+package main
+
+import "fmt"
+
+func double(x int) int {
+	return 2*x
+}
+
+func main() {
+	if double(21) != 42 {
+		panic("bug!")
+	}
+	fmt.Println("Hello world!")
+}
@@ -0,0 +1,85 @@
+#!/bin/sh
+
+set -ev
+
+O=5
+test "$GOARCH" = amd64 && O=6
+test "$GOARCH" = 386 && O=8
+
+# a package whose functions are inlined into its importers
+cat > geom.go <<EOF2
+package geom
+
+import "math"
+
+type Point struct { X, Y float64 }
+
+func Norm(p Point) float64 {
+	return math.Sqrt(Dot(p, p))
+}
+
+func Dot(a, b Point) float64 {
+	return a.X*b.X + a.Y*b.Y
+}
+
+type Circle struct { Center Point; radius float64 }
+
+func Radius(c Circle) float64 {
+	return c.radius
+}
+EOF2
+
+../go-crazy --just-translate --export-inline geom.inl geom.go
+grep 'func Dot(a, b geom.Point) float64' geom.inl
+grep 'math.Sqrt(geom.Dot(p, p))' geom.inl
+# an unexported field can't be reached from an importer
+grep 'func Radius' geom.inl && exit 1
+${O}g -o geom.$O geom-compiled.go
+
+# a package split over two files
+cat > cf_main.go <<EOF2
+package main
+
+import (
+	"fmt"
+	g "./geom"
+)
+
+func main() {
+	p := g.Point{3, 4}
+	fmt.Println(square(2), g.Dot(p, p))
+}
+EOF2
+
+cat > cf_helper.go <<EOF2
+package main
+
+func square(x int) int {
+	return x*x
+}
+EOF2
+
+../go-crazy cf_main.go cf_helper.go
+./cf_main > noinline.temp
+
+../go-crazy --inline square --inline geom.Dot --inline-from ./geom=geom.inl cf_main.go cf_helper.go
+grep 'square(2)' cf_main-compiled.go && exit 1
+grep 'g.Dot(p, p)' cf_main-compiled.go && exit 1
+grep 'b g.Point' cf_main-compiled.go
+./cf_main > inline.temp
+diff inline.temp noinline.temp
+
+# a function missing from the package or its export is an error
+../go-crazy --inline geom.Cross --inline-from ./geom=geom.inl cf_main.go cf_helper.go > errors.temp && exit 1
+grep 'no inlinable function geom.Cross' errors.temp
+../go-crazy --inline shape.Dot cf_main.go cf_helper.go > errors.temp && exit 1
+grep 'no inlinable function shape.Dot' errors.temp
+../go-crazy --inline cube cf_main.go cf_helper.go > errors.temp && exit 1
+grep 'no inlinable function cube' errors.temp
+
+# Norm calls Dot, so it isn't a leaf
+../go-crazy --inline-auto --inline-report --inline-from ./geom=geom.inl cf_main.go cf_helper.go > report.temp
+grep 'not inlining geom.Norm: not a leaf' report.temp
+grep 'inlining geom.Dot' report.temp
+
+echo Cross-file inlining works!
@@ -0,0 +1,15 @@
+#!/bin/sh
+
+set -ev
+
+./imports > before.temp
+
+# the rewrite leaves strings unused, so its import must go
+../go-crazy -r 'strings.TrimSpace(s) -> s' imports.go
+grep 'strings' imports-compiled.go && exit 1
+grep '"fmt"' imports-compiled.go
+
+./imports > after.temp
+diff before.temp after.temp
+
+echo Unused imports are removed!
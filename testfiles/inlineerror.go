@@ -0,0 +1,11 @@
+package main
+
+import "fmt"
+
+func triple(x int) int {
+	return 3 * x
+}
+
+func main() {
+	fmt.Println(triple(14))
+}
@@ -0,0 +1,26 @@
+#!/bin/sh
+
+set -ev
+
+# a function declared without a body can't be inlined
+cat > nobody.go <<NOBODY
+package main
+
+func twice(x int) int
+
+func main() {
+	println(twice(1))
+	println(twice(2))
+}
+NOBODY
+../go-crazy --just-translate --inline twice nobody.go > errors.temp && exit 1
+grep 'Error in pass inline:' errors.temp
+grep 'nobody.go:6:10: cannot inline twice: it has no body' errors.temp
+grep 'nobody.go:7:10: cannot inline twice: it has no body' errors.temp
+
+# a function with a body is inlined as before
+../go-crazy --inline triple inlineerror.go
+grep 'triple(14)' inlineerror-compiled.go && exit 1
+./inlineerror | grep 42
+
+echo Errors from inlining are reported!
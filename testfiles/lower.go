@@ -0,0 +1,25 @@
+package main
+
+import "fmt"
+
+type Vec []float64
+
+func (a Vec) .+ (b Vec) Vec {
+	return Vec{a[0] + b[0], a[1] + b[1]}
+}
+
+type Samples []float64
+
+func main() {
+	x, y := 1.5, 2.0
+	fmt.Println(x .+ y, x .* y .- 1, 2 *. y)
+	n := 7
+	fmt.Println(n ./ 2)
+	a := []float64{1, 2, 3}
+	b := []float64{4, 5, 6}
+	fmt.Println(a .+ b, a .* 2, 10 .- a, 3 *. b)
+	s := Samples{1, 2}
+	fmt.Println(s .* s)
+	v := Vec{1, 2}
+	fmt.Println(v .+ v)
+}
@@ -0,0 +1,21 @@
+#!/bin/sh
+
+set -ev
+
+./lower > lower.temp
+cat > expected.temp <<END
+3.5 2 4
+3
+[5 7 9] [2 4 6] [9 8 7] [12 15 18]
+[1 4]
+[2 4]
+END
+diff lower.temp expected.temp
+
+# numbers use the ordinary operators, and Vec keeps its method
+grep 'x + y' lower-compiled.go
+grep 'n / 2' lower-compiled.go
+grep 'v._dot_add(v)' lower-compiled.go
+grep '_dot_mul\|_mul_dot\|_dot_sub' lower-compiled.go && exit 1
+
+echo Dotted operators work on numbers and slices!
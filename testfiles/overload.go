@@ -0,0 +1,31 @@
+package main
+
+import "fmt"
+
+type Vec []float64
+
+// the dot product
+func (a Vec) .* (b Vec) float64 {
+	var sum float64
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+// scaling
+func (a Vec) .* (b float64) Vec {
+	c := make(Vec, len(a))
+	for i := range a {
+		c[i] = a[i] * b
+	}
+	return c
+}
+
+func main() {
+	v := Vec{1, 2, 3}
+	fmt.Println(v .* v)
+	fmt.Println(v .* 2)
+	w := v .* 0.5
+	fmt.Println(w .* v)
+}
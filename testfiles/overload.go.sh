@@ -0,0 +1,36 @@
+#!/bin/sh
+
+set -ev
+
+./overload > overload.temp
+cat > expected.temp <<END
+14
+[2 4 6]
+7
+END
+diff overload.temp expected.temp
+
+grep 'func (a Vec) _dot_mul_Vec(b Vec) float64' overload-compiled.go
+grep 'func (a Vec) _dot_mul_float64(b float64) Vec' overload-compiled.go
+grep 'w._dot_mul_Vec(v)' overload-compiled.go
+
+# an untyped constant can't choose between two kinds of float
+cat > ambiguous.go <<END
+package main
+
+type Vec []float64
+
+func (a Vec) .* (b float32) Vec { return a }
+
+func (a Vec) .* (b float64) Vec { return a }
+
+func main() {
+	v := Vec{1}
+	v = v .* 2
+}
+END
+../go-crazy --just-translate ambiguous.go > errors.temp && exit 1
+grep 'Error in pass overload-operators:' errors.temp
+grep 'ambiguous.go:11:8: cannot choose a method for .\* on Vec: an operand of type int matches both float32 and float64' errors.temp
+
+echo Operators are overloaded by operand type!
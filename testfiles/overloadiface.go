@@ -0,0 +1,38 @@
+package main
+
+import "fmt"
+
+// a Scaler scales a vector by some factor
+type Scaler interface {
+	Factor() float64
+}
+
+type Half struct{}
+
+func (h Half) Factor() float64 { return 0.5 }
+
+type Vec []float64
+
+// the dot product
+func (a Vec) .* (b Vec) float64 {
+	var sum float64
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+// scaling by any Scaler
+func (a Vec) .* (b Scaler) Vec {
+	c := make(Vec, len(a))
+	for i := range a {
+		c[i] = a[i] * b.Factor()
+	}
+	return c
+}
+
+func main() {
+	v := Vec{2, 4}
+	fmt.Println(v .* v)
+	fmt.Println(v .* Half{})
+}
@@ -0,0 +1,59 @@
+#!/bin/sh
+
+set -ev
+
+./overloadiface > overloadiface.temp
+cat > expected.temp <<END
+20
+[1 2]
+END
+diff overloadiface.temp expected.temp
+
+# an operand of a type implementing the interface chooses the method
+# taking the interface
+grep 'func (a Vec) _dot_mul_Scaler(b Scaler) Vec' overloadiface-compiled.go
+grep 'v._dot_mul_Scaler(Half{})' overloadiface-compiled.go
+
+# no type overloading an operator could satisfy an interface declaring it
+cat > ifacedecl.go <<END
+package main
+
+type Vec []float64
+
+type Multiplier interface {
+	.* (Vec) Vec
+}
+
+func (a Vec) .* (b Vec) Vec { return a }
+
+func (a Vec) .* (b float64) Vec { return a }
+
+func main() {
+	var m Multiplier
+	m = m .* Vec{2}
+}
+END
+../go-crazy --just-translate ifacedecl.go > errors.temp && exit 1
+grep 'Error in pass overload-operators:' errors.temp
+grep 'ifacedecl.go:6:2: cannot declare the overloaded operator .\* in an interface' errors.temp
+
+# nor can a method expression name one of its methods
+cat > methexpr.go <<END
+package main
+
+type Vec []float64
+
+func (a Vec) .* (b Vec) Vec { return a }
+
+func (a Vec) .* (b float64) Vec { return a }
+
+func main() {
+	f := Vec.(.*)
+	f(Vec{1}, 2)
+}
+END
+../go-crazy --just-translate methexpr.go > errors.temp && exit 1
+grep 'Error in pass overload-operators:' errors.temp
+grep 'methexpr.go:10:12: cannot use .\* on Vec other than by applying it, since it is overloaded' errors.temp
+
+echo Overloaded operators and interfaces get along!
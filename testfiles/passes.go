@@ -0,0 +1,13 @@
+package main
+
+import "fmt"
+
+func double(x int) int {
+	return 2 * x
+}
+
+func main() {
+	n := 1
+	n = n + 1
+	fmt.Println(double(n))
+}
@@ -0,0 +1,81 @@
+#!/bin/sh
+
+set -ev
+
+./passes > nopasses.temp
+
+# only the passes named with --passes are run
+../go-crazy -r 'n = n + 1 -> n++' --inline double --passes=inline --dump-after=inline passes.go > dump.temp
+grep '// after inline: passes.go' dump.temp
+grep 'n = n + 1' dump.temp
+grep 'n = n + 1' passes-compiled.go
+grep 'double(n)' passes-compiled.go && exit 1
+./passes > passes.temp
+diff passes.temp nopasses.temp
+
+# by default the passes asked for by the flags are run in order
+../go-crazy -r 'n = n + 1 -> n++' --inline double --time-passes passes.go > times.temp
+head -1 times.temp | grep 'pass rewrite: '
+sed -n 2p times.temp | grep 'pass operator-sections: '
+sed -n 3p times.temp | grep 'pass compound-operators: '
+sed -n 4p times.temp | grep 'pass overload-operators: '
+sed -n 5p times.temp | grep 'pass inline: '
+grep 'pass lower-operators: ' times.temp && exit 1
+tail -1 times.temp | grep 'pass unused-imports: '
+grep 'n++' passes-compiled.go
+./passes > passes.temp
+diff passes.temp nopasses.temp
+
+# a package without dotted operators needs no passes by default
+../go-crazy --time-passes passes.go > times.temp
+test ! -s times.temp
+
+../go-crazy --passes=bogus passes.go && exit 1
+../go-crazy --dump-after=bogus passes.go && exit 1
+
+# the passes, including any plugins, can be listed
+../go-crazy --list-transforms > list.temp
+grep '^rewrite  *apply the rules given with -r$' list.temp
+grep '^inline-auto  *inline small leaf functions' list.temp
+
+# a go-crazy built with a plugin runs it by default
+O=5
+test "$GOARCH" = amd64 && O=6
+test "$GOARCH" = 386 && O=8
+rm -rf plugged
+mkdir plugged
+cp ../*.go ../Makefile plugged/
+for d in parser scanner transform; do ln -s ../../$d plugged/$d; done
+cat > plugged/bump.go <<END
+package bump
+
+import (
+	"go/ast"
+	"go/token"
+	"github.com/droundy/go-crazy/transform"
+)
+
+type bumper struct{}
+
+func (v bumper) Visit(node interface{}) interface{} {
+	if lit, ok := node.(*ast.BasicLit); ok && lit.Kind == token.INT && string(lit.Value) == "1" {
+		lit.Value = []byte("2")
+	}
+	return nil
+}
+
+func init() {
+	transform.RegisterPlugin(&transform.Plugin{"bump", "turn every 1 into 2", nil, nil,
+		func() transform.Visitor { return bumper{} }})
+}
+END
+(cd plugged && ${O}g -o bump.$O bump.go && make PLUGINS=./bump)
+plugged/go-crazy --list-transforms | grep '^bump  *turn every 1 into 2$'
+plugged/go-crazy --time-passes passes.go > times.temp
+head -1 times.temp | grep 'pass bump: '
+tail -1 times.temp | grep 'pass unused-imports: '
+grep 'n := 2' passes-compiled.go
+./passes | grep '^8$'
+rm -rf plugged
+
+echo The pass manager works!
@@ -0,0 +1,31 @@
+package main
+
+import "fmt"
+
+func fact(n int) int {
+	if n <= 1 {
+		return 1
+	}
+	return n * fact(n-1)
+}
+
+func even(n int) bool {
+	if n == 0 {
+		return true
+	}
+	return odd(n-1)
+}
+
+func odd(n int) bool {
+	if n == 0 {
+		return false
+	}
+	return even(n-1)
+}
+
+func main() {
+	if fact(5) != 120 || !even(10) || odd(10) {
+		panic("bug!")
+	}
+	fmt.Println("Hello world!")
+}
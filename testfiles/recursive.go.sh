@@ -0,0 +1,25 @@
+#!/bin/sh
+
+set -ev
+
+./recursive > noinline.temp
+
+# recursive functions are left alone unless asked for a depth
+../go-crazy --inline fact --inline even --inline-report recursive.go > report.temp
+grep 'call to fact: not inlined: recursive' report.temp
+grep 'call to even: not inlined: recursive' report.temp
+grep 'func fact' recursive-compiled.go
+grep 'func even' recursive-compiled.go
+
+../go-crazy --inline fact --inline-depth=2 --inline-report recursive.go > report.temp
+grep 'call to fact: inlined as closure' report.temp
+grep 'call to fact: not inlined: recursion deeper than 2' report.temp
+# two levels within fact itself and within main, whichever comes first
+test `grep -c 'call to fact: inlined as closure' report.temp` = 4
+grep 'func fact' recursive-compiled.go
+./recursive > inline.temp
+diff inline.temp noinline.temp
+
+../go-crazy --inline-auto --inline-depth=2 --inline-report recursive.go | grep 'inlining fact: cost'
+
+echo Recursive inlining works!
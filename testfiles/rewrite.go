@@ -0,0 +1,23 @@
+package main
+
+import "fmt"
+
+type Vec []float64
+
+func add(a, b Vec) Vec {
+	return Vec{a[0] + b[0], a[1] + b[1]}
+}
+
+func (a Vec) .+ (b Vec) Vec {
+	return Vec{a[0] + b[0], a[1] + b[1]}
+}
+
+func main() {
+	x := Vec{1, 2}
+	y := Vec{3, 4}
+	z := x .+ y
+	fmt.Println(z[0], z[1])
+	n := 0
+	n = n + 1
+	fmt.Println(n)
+}
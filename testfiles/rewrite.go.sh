@@ -0,0 +1,19 @@
+#!/bin/sh
+
+set -ev
+
+./rewrite > norewrite.temp
+
+../go-crazy -r 'a .+ b -> add(a, b)' -r 'n = n + 1 -> n++' rewrite.go
+grep 'z := add(x, y)' rewrite-compiled.go
+grep 'n++' rewrite-compiled.go
+grep '_dot_add(' rewrite-compiled.go && exit 1
+
+./rewrite > rewrite.temp
+
+diff rewrite.temp norewrite.temp
+
+# a rule must have an arrow
+../go-crazy -r 'a .+ b' rewrite.go && exit 1
+
+echo Rewriting works!
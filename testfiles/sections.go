@@ -0,0 +1,27 @@
+package main
+
+import "fmt"
+
+type Vec []float64
+
+func (a Vec) .+ (b Vec) Vec {
+	c := make(Vec, len(a))
+	for i := range a {
+		c[i] = a[i] + b[i]
+	}
+	return c
+}
+
+func apply(f func(Vec) Vec, v Vec) Vec { return f(v) }
+
+func main() {
+	v := Vec{1, 2, 3}
+	add := Vec.(.+)
+	fmt.Println(add(v, v))
+	fmt.Println(apply((.+ v), Vec{10, 20, 30}))
+	fmt.Println(apply((v .+), Vec{1, 1, 1}))
+	double := (2 .*)
+	fmt.Println(double(21))
+	scale := (.* float64(0.5))
+	fmt.Println(scale(3))
+}
@@ -0,0 +1,51 @@
+#!/bin/sh
+
+set -ev
+
+./sections > sections.temp
+cat > expected.temp <<END
+[2 4 6]
+[11 22 33]
+[2 3 4]
+42
+1.5
+END
+diff sections.temp expected.temp
+
+grep 'add := Vec._dot_add' sections-compiled.go
+
+# a section must be given one of its operands
+cat > nooperands.go <<END
+package main
+
+func main() {
+	f := (.+)
+}
+END
+../go-crazy --just-translate nooperands.go > errors.temp && exit 1
+grep 'Error in pass operator-sections:' errors.temp
+grep 'nooperands.go:4:8: the section of .+ needs one of its operands' errors.temp
+
+# nor be made of an overloaded operator, or guess the type of its
+# receiver
+cat > overloaded.go <<END
+package main
+
+type Vec []float64
+
+func (a Vec) .* (b Vec) float64 { return 0 }
+
+func (a Vec) .* (b float64) Vec { return a }
+
+func main() {
+	v := Vec{1}
+	f := (v .*)
+	g := (.* 0.5)
+}
+END
+../go-crazy --just-translate overloaded.go > errors.temp && exit 1
+grep 'Error in pass operator-sections:' errors.temp
+grep 'overloaded.go:11:10: cannot make a section of .\*: Vec overloads it' errors.temp
+grep 'overloaded.go:12:8: cannot make a section of .\*: the type of its receiver is unknown' errors.temp
+
+echo Operators have sections!
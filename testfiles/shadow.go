@@ -0,0 +1,15 @@
+package main
+
+import "fmt"
+
+func double(x int) int {
+	return 2 * x
+}
+
+func main() {
+	fmt.Println(double(21))
+	{
+		double := func(x int) int { return x + x + 1 }
+		fmt.Println(double(21))
+	}
+}
@@ -0,0 +1,15 @@
+#!/bin/sh
+
+set -ev
+
+./shadow > noinline.temp
+
+# the local double shadows the function, and must not be inlined
+../go-crazy --inline double shadow.go
+test `grep -c 'double(21)' shadow-compiled.go` = 1
+
+./shadow > inline.temp
+
+diff inline.temp noinline.temp
+
+echo Shadowed names are left alone!
@@ -0,0 +1,639 @@
+// Copyright 2010 David Roundy, roundyd@physics.oregonstate.edu.
+// All rights reserved.
+
+package transform
+
+import (
+	"container/vector"
+	"go/ast"
+)
+
+
+// An ApplyFunc is called by Apply for each node, with a Cursor
+// describing the node and where it lies in its parent.  Its result
+// controls the traversal; see Apply.
+type ApplyFunc func(c *Cursor) bool
+
+
+// Apply traverses an AST in depth-first order, much as Walk does,
+// calling pre for each node before its children are traversed and
+// post for each node afterwards.  Either function may be nil.
+//
+// If pre returns false, the children of the node and post are skipped
+// for that node.  If post returns false, the traversal stops
+// altogether.  If pre replaces the node, the children of the
+// replacement are traversed instead; nodes inserted before or after
+// the current one are not traversed.  Absent optional children are
+// skipped, as they are by Walk.
+//
+// Apply may be called with any of the named ast node types, and with
+// any type registered with RegisterNode.  It returns root, or its
+// replacement.
+//
+func Apply(root interface{}, pre, post ApplyFunc) interface{} {
+	if root == nil {
+		return nil
+	}
+	a := applier{pre: pre, post: post}
+	return a.apply(&Cursor{nil, "", nil, nil, root})
+}
+
+
+// A Cursor describes a node visited by Apply, and provides
+// operations to modify the AST around it.
+type Cursor struct {
+	parent interface{}
+	name   string
+	list   *vector.Vector // the list holding node, if any
+	iter   *iterator      // the position of node within list
+	node   interface{}
+}
+
+
+type iterator struct {
+	index, step int
+}
+
+
+// Node returns the current node, which is nil if it has been deleted.
+func (c *Cursor) Node() interface{} { return c.node }
+
+
+// Parent returns the node that holds the current node, or nil if the
+// current node is the root passed to Apply.
+func (c *Cursor) Parent() interface{} { return c.parent }
+
+
+// Name returns the name of the field of Parent that holds the current
+// node, such as "Body" or "Else".
+func (c *Cursor) Name() string { return c.name }
+
+
+// Index returns the index of the current node within the slice field
+// of Parent that holds it, or -1 if the field is not a slice.
+func (c *Cursor) Index() int {
+	if c.list == nil {
+		return -1
+	}
+	return c.iter.index
+}
+
+
+// Replace replaces the current node with n.  To remove a node from a
+// slice, use Delete rather than replacing it with nil.
+func (c *Cursor) Replace(n interface{}) {
+	if c.list != nil {
+		c.list.Set(c.iter.index, n)
+	}
+	c.node = n
+}
+
+
+// Delete removes the current node from the slice that holds it.  It
+// panics if the node is not held in a slice.
+func (c *Cursor) Delete() {
+	if c.list == nil {
+		panic("transform.Cursor.Delete: node is not in a slice")
+	}
+	c.list.Delete(c.iter.index)
+	c.iter.step--
+	c.node = nil
+}
+
+
+// InsertAfter inserts n after the current node in the slice that holds
+// it.  It panics if the node is not held in a slice.
+func (c *Cursor) InsertAfter(n interface{}) {
+	if c.list == nil {
+		panic("transform.Cursor.InsertAfter: node is not in a slice")
+	}
+	c.list.Insert(c.iter.index+1, n)
+	c.iter.step++
+}
+
+
+// InsertBefore inserts n before the current node in the slice that
+// holds it.  It panics if the node is not held in a slice.
+func (c *Cursor) InsertBefore(n interface{}) {
+	if c.list == nil {
+		panic("transform.Cursor.InsertBefore: node is not in a slice")
+	}
+	c.list.Insert(c.iter.index, n)
+	c.iter.index++
+}
+
+
+type applier struct {
+	pre, post ApplyFunc
+	stop      bool
+}
+
+
+func (a *applier) apply(c *Cursor) interface{} {
+	if a.pre != nil && !a.pre(c) {
+		return c.node
+	}
+	if c.node == nil {
+		return nil
+	}
+	a.children(c.node)
+	if a.stop {
+		return c.node
+	}
+	if a.post != nil && !a.post(c) {
+		a.stop = true
+	}
+	return c.node
+}
+
+
+func (a *applier) node(parent interface{}, name string, n interface{}) interface{} {
+	if a.stop {
+		return n
+	}
+	return a.apply(&Cursor{parent, name, nil, nil, n})
+}
+
+
+// list applies a to each element of l in turn, keeping track of the
+// insertions and deletions made through the Cursor.
+func (a *applier) list(parent interface{}, name string, l *vector.Vector) {
+	it := new(iterator)
+	for it.index < l.Len() && !a.stop {
+		it.step = 1
+		a.apply(&Cursor{parent, name, l, it, l.At(it.index)})
+		it.index += it.step
+	}
+}
+
+
+func (a *applier) ident(parent interface{}, name string, x *ast.Ident) *ast.Ident {
+	if x != nil {
+		if y := a.node(parent, name, x); y != nil {
+			return y.(*ast.Ident)
+		}
+	}
+	return nil
+}
+
+
+func (a *applier) basicLit(parent interface{}, name string, x *ast.BasicLit) *ast.BasicLit {
+	if x != nil {
+		if y := a.node(parent, name, x); y != nil {
+			return y.(*ast.BasicLit)
+		}
+	}
+	return nil
+}
+
+
+func (a *applier) commentGroup(parent interface{}, name string, g *ast.CommentGroup) *ast.CommentGroup {
+	if g != nil {
+		if y := a.node(parent, name, g); y != nil {
+			return y.(*ast.CommentGroup)
+		}
+	}
+	return nil
+}
+
+
+func (a *applier) fieldList(parent interface{}, name string, l *ast.FieldList) *ast.FieldList {
+	if l != nil {
+		if y := a.node(parent, name, l); y != nil {
+			return y.(*ast.FieldList)
+		}
+	}
+	return nil
+}
+
+
+func (a *applier) funcType(parent interface{}, name string, t *ast.FuncType) *ast.FuncType {
+	if t != nil {
+		if y := a.node(parent, name, t); y != nil {
+			return y.(*ast.FuncType)
+		}
+	}
+	return nil
+}
+
+
+func (a *applier) blockStmt(parent interface{}, name string, b *ast.BlockStmt) *ast.BlockStmt {
+	if b != nil {
+		if y := a.node(parent, name, b); y != nil {
+			return y.(*ast.BlockStmt)
+		}
+	}
+	return nil
+}
+
+
+func (a *applier) callExpr(parent interface{}, name string, x *ast.CallExpr) *ast.CallExpr {
+	if x != nil {
+		if y := a.node(parent, name, x); y != nil {
+			return y.(*ast.CallExpr)
+		}
+	}
+	return nil
+}
+
+
+func (a *applier) expr(parent interface{}, name string, x ast.Expr) ast.Expr {
+	if x != nil {
+		if y := a.node(parent, name, x); y != nil {
+			return y.(ast.Expr)
+		}
+	}
+	return nil
+}
+
+
+func (a *applier) stmt(parent interface{}, name string, s ast.Stmt) ast.Stmt {
+	if s != nil {
+		if y := a.node(parent, name, s); y != nil {
+			return y.(ast.Stmt)
+		}
+	}
+	return nil
+}
+
+
+func (a *applier) decl(parent interface{}, name string, d ast.Decl) ast.Decl {
+	if d != nil {
+		if y := a.node(parent, name, d); y != nil {
+			return y.(ast.Decl)
+		}
+	}
+	return nil
+}
+
+
+func (a *applier) comments(parent interface{}, name string, l []*ast.Comment) []*ast.Comment {
+	v := new(vector.Vector)
+	for _, x := range l {
+		v.Push(x)
+	}
+	a.list(parent, name, v)
+	if v.Len() == 0 {
+		return l[0:0]
+	}
+	r := make([]*ast.Comment, v.Len())
+	for i, x := range *v {
+		r[i] = x.(*ast.Comment)
+	}
+	return r
+}
+
+
+func (a *applier) commentGroups(parent interface{}, name string, l []*ast.CommentGroup) []*ast.CommentGroup {
+	v := new(vector.Vector)
+	for _, x := range l {
+		v.Push(x)
+	}
+	a.list(parent, name, v)
+	if v.Len() == 0 {
+		return l[0:0]
+	}
+	r := make([]*ast.CommentGroup, v.Len())
+	for i, x := range *v {
+		r[i] = x.(*ast.CommentGroup)
+	}
+	return r
+}
+
+
+func (a *applier) fields(parent interface{}, name string, l []*ast.Field) []*ast.Field {
+	v := new(vector.Vector)
+	for _, x := range l {
+		v.Push(x)
+	}
+	a.list(parent, name, v)
+	if v.Len() == 0 {
+		return l[0:0]
+	}
+	r := make([]*ast.Field, v.Len())
+	for i, x := range *v {
+		r[i] = x.(*ast.Field)
+	}
+	return r
+}
+
+
+func (a *applier) idents(parent interface{}, name string, l []*ast.Ident) []*ast.Ident {
+	v := new(vector.Vector)
+	for _, x := range l {
+		v.Push(x)
+	}
+	a.list(parent, name, v)
+	if v.Len() == 0 {
+		return l[0:0]
+	}
+	r := make([]*ast.Ident, v.Len())
+	for i, x := range *v {
+		r[i] = x.(*ast.Ident)
+	}
+	return r
+}
+
+
+func (a *applier) exprs(parent interface{}, name string, l []ast.Expr) []ast.Expr {
+	v := new(vector.Vector)
+	for _, x := range l {
+		v.Push(x)
+	}
+	a.list(parent, name, v)
+	if v.Len() == 0 {
+		return l[0:0]
+	}
+	r := make([]ast.Expr, v.Len())
+	for i, x := range *v {
+		r[i] = x.(ast.Expr)
+	}
+	return r
+}
+
+
+func (a *applier) stmts(parent interface{}, name string, l []ast.Stmt) []ast.Stmt {
+	v := new(vector.Vector)
+	for _, x := range l {
+		v.Push(x)
+	}
+	a.list(parent, name, v)
+	if v.Len() == 0 {
+		return l[0:0]
+	}
+	r := make([]ast.Stmt, v.Len())
+	for i, x := range *v {
+		r[i] = x.(ast.Stmt)
+	}
+	return r
+}
+
+
+func (a *applier) specs(parent interface{}, name string, l []ast.Spec) []ast.Spec {
+	v := new(vector.Vector)
+	for _, x := range l {
+		v.Push(x)
+	}
+	a.list(parent, name, v)
+	if v.Len() == 0 {
+		return l[0:0]
+	}
+	r := make([]ast.Spec, v.Len())
+	for i, x := range *v {
+		r[i] = x.(ast.Spec)
+	}
+	return r
+}
+
+
+func (a *applier) decls(parent interface{}, name string, l []ast.Decl) []ast.Decl {
+	v := new(vector.Vector)
+	for _, x := range l {
+		v.Push(x)
+	}
+	a.list(parent, name, v)
+	if v.Len() == 0 {
+		return l[0:0]
+	}
+	r := make([]ast.Decl, v.Len())
+	for i, x := range *v {
+		r[i] = x.(ast.Decl)
+	}
+	return r
+}
+
+
+// children applies a to the children of node, in the order used by
+// Walk.
+func (a *applier) children(node interface{}) {
+	switch n := node.(type) {
+	// Comments and fields
+	case *ast.Comment:
+		// nothing to do
+
+	case *ast.CommentGroup:
+		n.List = a.comments(n, "List", n.List)
+
+	case *ast.Field:
+		n.Doc = a.commentGroup(n, "Doc", n.Doc)
+		n.Names = a.idents(n, "Names", n.Names)
+		n.Type = a.expr(n, "Type", n.Type)
+		n.Tag = a.basicLit(n, "Tag", n.Tag)
+		n.Comment = a.commentGroup(n, "Comment", n.Comment)
+
+	case *ast.FieldList:
+		n.List = a.fields(n, "List", n.List)
+
+	// Expressions
+	case *ast.BadExpr, *ast.Ident, *ast.BasicLit:
+		// nothing to do
+
+	case *ast.Ellipsis:
+		n.Elt = a.expr(n, "Elt", n.Elt)
+
+	case *ast.FuncLit:
+		n.Type = a.funcType(n, "Type", n.Type)
+		n.Body = a.blockStmt(n, "Body", n.Body)
+
+	case *ast.CompositeLit:
+		n.Type = a.expr(n, "Type", n.Type)
+		n.Elts = a.exprs(n, "Elts", n.Elts)
+
+	case *ast.ParenExpr:
+		n.X = a.expr(n, "X", n.X)
+
+	case *ast.SelectorExpr:
+		n.X = a.expr(n, "X", n.X)
+		n.Sel = a.ident(n, "Sel", n.Sel)
+
+	case *ast.IndexExpr:
+		n.X = a.expr(n, "X", n.X)
+		n.Index = a.expr(n, "Index", n.Index)
+
+	case *ast.SliceExpr:
+		n.X = a.expr(n, "X", n.X)
+		n.Index = a.expr(n, "Index", n.Index)
+		n.End = a.expr(n, "End", n.End)
+
+	case *ast.TypeAssertExpr:
+		n.X = a.expr(n, "X", n.X)
+		n.Type = a.expr(n, "Type", n.Type)
+
+	case *ast.CallExpr:
+		n.Fun = a.expr(n, "Fun", n.Fun)
+		n.Args = a.exprs(n, "Args", n.Args)
+
+	case *ast.StarExpr:
+		n.X = a.expr(n, "X", n.X)
+
+	case *ast.UnaryExpr:
+		n.X = a.expr(n, "X", n.X)
+
+	case *ast.BinaryExpr:
+		n.X = a.expr(n, "X", n.X)
+		n.Y = a.expr(n, "Y", n.Y)
+
+	case *ast.KeyValueExpr:
+		n.Key = a.expr(n, "Key", n.Key)
+		n.Value = a.expr(n, "Value", n.Value)
+
+	// Types
+	case *ast.ArrayType:
+		n.Len = a.expr(n, "Len", n.Len)
+		n.Elt = a.expr(n, "Elt", n.Elt)
+
+	case *ast.StructType:
+		n.Fields = a.fieldList(n, "Fields", n.Fields)
+
+	case *ast.FuncType:
+		n.Params = a.fieldList(n, "Params", n.Params)
+		n.Results = a.fieldList(n, "Results", n.Results)
+
+	case *ast.InterfaceType:
+		n.Methods = a.fieldList(n, "Methods", n.Methods)
+
+	case *ast.MapType:
+		n.Key = a.expr(n, "Key", n.Key)
+		n.Value = a.expr(n, "Value", n.Value)
+
+	case *ast.ChanType:
+		n.Value = a.expr(n, "Value", n.Value)
+
+	// Statements
+	case *ast.BadStmt, *ast.EmptyStmt:
+		// nothing to do
+
+	case *ast.DeclStmt:
+		n.Decl = a.decl(n, "Decl", n.Decl)
+
+	case *ast.LabeledStmt:
+		n.Label = a.ident(n, "Label", n.Label)
+		n.Stmt = a.stmt(n, "Stmt", n.Stmt)
+
+	case *ast.ExprStmt:
+		n.X = a.expr(n, "X", n.X)
+
+	case *ast.IncDecStmt:
+		n.X = a.expr(n, "X", n.X)
+
+	case *ast.AssignStmt:
+		n.Lhs = a.exprs(n, "Lhs", n.Lhs)
+		n.Rhs = a.exprs(n, "Rhs", n.Rhs)
+
+	case *ast.GoStmt:
+		n.Call = a.callExpr(n, "Call", n.Call)
+
+	case *ast.DeferStmt:
+		n.Call = a.callExpr(n, "Call", n.Call)
+
+	case *ast.ReturnStmt:
+		n.Results = a.exprs(n, "Results", n.Results)
+
+	case *ast.BranchStmt:
+		n.Label = a.ident(n, "Label", n.Label)
+
+	case *ast.BlockStmt:
+		n.List = a.stmts(n, "List", n.List)
+
+	case *ast.IfStmt:
+		n.Init = a.stmt(n, "Init", n.Init)
+		n.Cond = a.expr(n, "Cond", n.Cond)
+		n.Body = a.blockStmt(n, "Body", n.Body)
+		n.Else = a.stmt(n, "Else", n.Else)
+
+	case *ast.CaseClause:
+		n.Values = a.exprs(n, "Values", n.Values)
+		n.Body = a.stmts(n, "Body", n.Body)
+
+	case *ast.SwitchStmt:
+		n.Init = a.stmt(n, "Init", n.Init)
+		n.Tag = a.expr(n, "Tag", n.Tag)
+		n.Body = a.blockStmt(n, "Body", n.Body)
+
+	case *ast.TypeCaseClause:
+		n.Types = a.exprs(n, "Types", n.Types)
+		n.Body = a.stmts(n, "Body", n.Body)
+
+	case *ast.TypeSwitchStmt:
+		n.Init = a.stmt(n, "Init", n.Init)
+		n.Assign = a.stmt(n, "Assign", n.Assign)
+		n.Body = a.blockStmt(n, "Body", n.Body)
+
+	case *ast.CommClause:
+		n.Lhs = a.expr(n, "Lhs", n.Lhs)
+		n.Rhs = a.expr(n, "Rhs", n.Rhs)
+		n.Body = a.stmts(n, "Body", n.Body)
+
+	case *ast.SelectStmt:
+		n.Body = a.blockStmt(n, "Body", n.Body)
+
+	case *ast.ForStmt:
+		n.Init = a.stmt(n, "Init", n.Init)
+		n.Cond = a.expr(n, "Cond", n.Cond)
+		n.Post = a.stmt(n, "Post", n.Post)
+		n.Body = a.blockStmt(n, "Body", n.Body)
+
+	case *ast.RangeStmt:
+		n.Key = a.expr(n, "Key", n.Key)
+		n.Value = a.expr(n, "Value", n.Value)
+		n.X = a.expr(n, "X", n.X)
+		n.Body = a.blockStmt(n, "Body", n.Body)
+
+	// Declarations
+	case *ast.ImportSpec:
+		n.Doc = a.commentGroup(n, "Doc", n.Doc)
+		n.Name = a.ident(n, "Name", n.Name)
+		n.Path = a.basicLit(n, "Path", n.Path)
+		n.Comment = a.commentGroup(n, "Comment", n.Comment)
+
+	case *ast.ValueSpec:
+		n.Doc = a.commentGroup(n, "Doc", n.Doc)
+		n.Names = a.idents(n, "Names", n.Names)
+		n.Type = a.expr(n, "Type", n.Type)
+		n.Values = a.exprs(n, "Values", n.Values)
+		n.Comment = a.commentGroup(n, "Comment", n.Comment)
+
+	case *ast.TypeSpec:
+		n.Doc = a.commentGroup(n, "Doc", n.Doc)
+		n.Name = a.ident(n, "Name", n.Name)
+		n.Type = a.expr(n, "Type", n.Type)
+		n.Comment = a.commentGroup(n, "Comment", n.Comment)
+
+	case *ast.BadDecl:
+		// nothing to do
+
+	case *ast.GenDecl:
+		n.Doc = a.commentGroup(n, "Doc", n.Doc)
+		n.Specs = a.specs(n, "Specs", n.Specs)
+
+	case *ast.FuncDecl:
+		n.Doc = a.commentGroup(n, "Doc", n.Doc)
+		n.Recv = a.fieldList(n, "Recv", n.Recv)
+		n.Name = a.ident(n, "Name", n.Name)
+		n.Type = a.funcType(n, "Type", n.Type)
+		n.Body = a.blockStmt(n, "Body", n.Body)
+
+	// Files and packages
+	case *ast.File:
+		n.Doc = a.commentGroup(n, "Doc", n.Doc)
+		n.Name = a.ident(n, "Name", n.Name)
+		n.Decls = a.decls(n, "Decls", n.Decls)
+		n.Comments = a.commentGroups(n, "Comments", n.Comments)
+
+	case *ast.Package:
+		for name, f := range n.Files {
+			if y := a.node(n, "Files", f); y != nil {
+				n.Files[name] = y.(*ast.File)
+			}
+		}
+
+	default:
+		visit := func(child interface{}) interface{} {
+			if y := a.node(n, "Children", child); y != nil {
+				return y
+			}
+			return child
+		}
+		nodeType(n).Walk(childVisitor(visit), n)
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2010 David Roundy, roundyd@physics.oregonstate.edu.
+// All rights reserved.
+
+package transform
+
+import (
+	"bytes"
+	"go/ast"
+	"go/printer"
+	"go/token"
+	"strings"
+	"testing"
+	"github.com/droundy/go-crazy/parser"
+)
+
+
+const applySrc = `package p
+
+func f(x int) int {
+	x++
+	if x > 0 {
+		return x
+	}
+	x--
+	return -x
+}
+`
+
+
+func TestApply(t *testing.T) {
+	f, err := parser.ParseFile("", applySrc, 0)
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+	returns := 0
+	pre := func(c *Cursor) bool {
+		switch n := c.Node().(type) {
+		case *ast.IncDecStmt:
+			if c.Name() != "List" || c.Index() < 0 {
+				t.Errorf("%T found in %s at %d", n, c.Name(), c.Index())
+			}
+			if n.Tok == token.DEC {
+				c.Delete()
+			}
+		case *ast.ReturnStmt:
+			returns++
+			if _, ok := c.Parent().(*ast.BlockStmt); !ok {
+				t.Errorf("return found in %T", c.Parent())
+			}
+			trace := &ast.ExprStmt{&ast.CallExpr{ast.NewIdent("println"), n.Pos(), nil, n.Pos(), n.Pos()}}
+			c.InsertBefore(trace)
+		case *ast.Ident:
+			if n.Name == "x" && c.Name() != "Names" {
+				c.Replace(&ast.ParenExpr{n.Pos(), n, n.Pos()})
+				return false
+			}
+		}
+		return true
+	}
+	Apply(f, pre, nil)
+	if returns != 2 {
+		t.Errorf("%d returns visited, want 2", returns)
+	}
+
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, f); err != nil {
+		t.Fatalf("Fprint: %v", err)
+	}
+	out := buf.String()
+	for _, s := range []string{"func f(x int) int", "(x)++", "println()\n\t\treturn (x)", "println()\n\treturn -(x)"} {
+		if strings.Index(out, s) < 0 {
+			t.Errorf("expected %q in\n%s", s, out)
+		}
+	}
+	if strings.Index(out, "--") >= 0 {
+		t.Errorf("x-- was not deleted:\n%s", out)
+	}
+}
+
+
+func TestApplyStop(t *testing.T) {
+	f, err := parser.ParseFile("", applySrc, 0)
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+	calls := 0
+	post := func(c *Cursor) bool {
+		calls++
+		_, ok := c.Node().(*ast.IfStmt)
+		return !ok
+	}
+	Apply(f, nil, post)
+	seen := calls
+	calls = 0
+	Apply(f, nil, func(c *Cursor) bool { calls++; return true })
+	if seen >= calls {
+		t.Errorf("stopping at the if statement visited %d of %d nodes", seen, calls)
+	}
+}
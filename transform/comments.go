@@ -0,0 +1,270 @@
+// Copyright 2010 David Roundy, roundyd@physics.oregonstate.edu.
+// All rights reserved.
+
+package transform
+
+import (
+	"container/vector"
+	"go/ast"
+	"go/token"
+)
+
+
+// A CommentMap attaches each comment group of a file or package to
+// the node it belongs to, so that transforms may carry comments along
+// with the nodes they move, copy or replace.  Comment groups are
+// attached to declarations, statements, specs and fields, or to the
+// file itself if none of those will do:
+//
+//	- a Doc or Comment group belongs to the node holding it;
+//	- a group following a node on the line on which the node ends
+//	  belongs to that node;
+//	- any other group belongs to the node that follows it within the
+//	  innermost node enclosing it, or else to that enclosing node.
+//
+// Once a transform is done, Update makes the comment lists of the
+// files agree with the map.  A nil *CommentMap holds no comments, and
+// its methods do nothing.
+type CommentMap struct {
+	groups map[interface{}][]*ast.CommentGroup // the groups attached to each node
+	pos    map[interface{}]token.Position      // where each node was when its groups were attached
+}
+
+
+// NewCommentMap returns the comment map of node, which is a File or
+// a Package whose files were parsed with their comments.
+func NewCommentMap(node interface{}) *CommentMap {
+	m := &CommentMap{make(map[interface{}][]*ast.CommentGroup), make(map[interface{}]token.Position)}
+	switch n := node.(type) {
+	case *ast.Package:
+		for _, f := range n.Files {
+			m.addFile(f)
+		}
+	case *ast.File:
+		m.addFile(n)
+	}
+	return m
+}
+
+
+// An anchor is a node to which comment groups may be attached.
+type anchor struct {
+	node interface{}
+	span Span
+}
+
+
+func isAnchor(node interface{}) bool {
+	switch node.(type) {
+	case ast.Decl, ast.Stmt, ast.Spec, *ast.Field, *ast.File:
+		return true
+	}
+	return false
+}
+
+
+func (m *CommentMap) addFile(f *ast.File) {
+	spans := make(map[interface{}]Span)
+	walkSpans(f, nil, func(n interface{}, s Span) { spans[n] = s })
+	var anchors vector.Vector // in the order in which Apply reaches them
+	owners := make(map[*ast.CommentGroup]interface{})
+	Apply(f, func(c *Cursor) bool {
+		if g, ok := c.Node().(*ast.CommentGroup); ok {
+			if c.Name() == "Doc" || c.Name() == "Comment" {
+				owners[g] = c.Parent()
+			}
+			return false
+		}
+		if isAnchor(c.Node()) {
+			anchors.Push(&anchor{c.Node(), spans[c.Node()]})
+		}
+		return true
+	}, nil)
+	for _, g := range f.Comments {
+		owner, ok := owners[g]
+		if !ok {
+			owner = attachment(g, anchors)
+		}
+		m.attach(owner, g, spans[owner].Start)
+	}
+}
+
+
+// attachment returns the anchor to which the free-standing group g
+// belongs.  anchors are in the order in which Apply reaches them, so
+// that an enclosing anchor comes before those it encloses.
+func attachment(g *ast.CommentGroup, anchors vector.Vector) interface{} {
+	start := g.List[0].Pos()
+	end := g.List[len(g.List)-1].Pos()
+	var trailing, enclosing *anchor
+	for _, a := range anchors {
+		a := a.(*anchor)
+		s := a.span
+		if !s.IsValid() {
+			continue
+		}
+		if s.End.Line == start.Line && s.End.Offset < start.Offset &&
+			(trailing == nil || s.End.Offset > trailing.span.End.Offset) {
+			trailing = a
+		}
+		if s.Start.Offset < start.Offset && s.End.Offset > end.Offset {
+			enclosing = a
+		}
+	}
+	if trailing != nil {
+		return trailing.node
+	}
+	for _, a := range anchors {
+		a := a.(*anchor)
+		s := a.span
+		if s.IsValid() && s.Start.Offset > end.Offset &&
+			(enclosing == nil || s.End.Offset < enclosing.span.End.Offset) {
+			return a.node
+		}
+	}
+	if enclosing != nil {
+		return enclosing.node
+	}
+	return anchors.At(0).(*anchor).node // the file
+}
+
+
+// attach attaches g to node, which starts at pos unless it already
+// has groups attached.
+func (m *CommentMap) attach(node interface{}, g *ast.CommentGroup, pos token.Position) {
+	if _, ok := m.pos[node]; !ok {
+		m.pos[node] = pos
+	}
+	list := make([]*ast.CommentGroup, len(m.groups[node])+1)
+	copy(list, m.groups[node])
+	list[len(list)-1] = g
+	m.groups[node] = list
+}
+
+
+// Groups returns the comment groups attached to node.
+func (m *CommentMap) Groups(node interface{}) []*ast.CommentGroup {
+	if m == nil {
+		return nil
+	}
+	return m.groups[node]
+}
+
+
+// Move attaches the comment groups of from to to, which replaces it.
+func (m *CommentMap) Move(from, to interface{}) {
+	if m == nil || from == to {
+		return
+	}
+	for _, g := range m.groups[from] {
+		m.attach(to, g, m.pos[from])
+	}
+	m.groups[from] = nil, false
+	m.pos[from] = token.Position{}, false
+}
+
+
+// Duplicate attaches to each node within to, which must be a copy of
+// from, a copy of the comment groups of the corresponding node within
+// from.  The copies are placed as the originals were, relative to
+// the nodes they are attached to.
+func (m *CommentMap) Duplicate(from, to interface{}) {
+	if m == nil {
+		return
+	}
+	a, b := preorder(from), preorder(to)
+	if len(a) != len(b) {
+		panic("CommentMap.Duplicate: not a copy")
+	}
+	for i, n := range a {
+		groups := m.groups[n]
+		if len(groups) == 0 {
+			continue
+		}
+		list := make([]*ast.CommentGroup, len(groups))
+		for j, g := range groups {
+			list[j] = Copy(g).(*ast.CommentGroup)
+		}
+		m.groups[b[i]] = list
+		m.pos[b[i]] = m.pos[n]
+	}
+}
+
+
+// preorder returns the nodes within node in the order in which Apply
+// reaches them.
+func preorder(node interface{}) []interface{} {
+	var nodes vector.Vector
+	Apply(node, func(c *Cursor) bool {
+		nodes.Push(c.Node())
+		return true
+	}, nil)
+	return nodes
+}
+
+
+// Update sets the comment list of each file within node, a File or a
+// Package, to the comment groups attached to the nodes now within it,
+// in order of position.  The groups of a node that has moved since
+// they were attached are placed just before it, and the groups of
+// nodes no longer in the file are dropped.
+func (m *CommentMap) Update(node interface{}) {
+	if m == nil {
+		return
+	}
+	switch n := node.(type) {
+	case *ast.Package:
+		for _, f := range n.Files {
+			m.updateFile(f)
+		}
+	case *ast.File:
+		m.updateFile(n)
+	}
+}
+
+
+func (m *CommentMap) updateFile(f *ast.File) {
+	spans := make(map[interface{}]Span)
+	walkSpans(f, nil, func(n interface{}, s Span) { spans[n] = s })
+	var groups vector.Vector
+	for _, n := range preorder(f) {
+		list, ok := m.groups[n]
+		if !ok {
+			continue
+		}
+		s := spans[n]
+		if old := m.pos[n]; s.IsValid() && (old.Filename != s.Start.Filename || old.Offset != s.Start.Offset) {
+			for _, g := range list {
+				place(g, s.Start)
+			}
+			m.pos[n] = s.Start
+		}
+		for _, g := range list {
+			groups.Push(g)
+		}
+	}
+	// sort the groups by position, keeping those placed together in
+	// order
+	for i := 1; i < groups.Len(); i++ {
+		for j := i; j > 0 && groupOffset(groups.At(j)) < groupOffset(groups.At(j-1)); j-- {
+			groups.Swap(j, j-1)
+		}
+	}
+	f.Comments = make([]*ast.CommentGroup, groups.Len())
+	for i, g := range groups {
+		f.Comments[i] = g.(*ast.CommentGroup)
+	}
+}
+
+
+func groupOffset(g interface{}) int { return g.(*ast.CommentGroup).List[0].Pos().Offset }
+
+
+// place moves the comments of g to just before pos, so that they are
+// printed before the token there.
+func place(g *ast.CommentGroup, pos token.Position) {
+	pos.Offset--
+	for _, c := range g.List {
+		c.Position = pos
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2010 David Roundy, roundyd@physics.oregonstate.edu.
+// All rights reserved.
+
+package transform
+
+import (
+	"go/ast"
+	"go/token"
+	"testing"
+	"github.com/droundy/go-crazy/parser"
+)
+
+
+const commentsSrc = `package p
+
+// f does things.
+func f(x int) int {
+	// double it
+	x *= 2
+	x++ // bump
+	return x
+	// done
+}
+
+func g() {
+	println(1)
+}
+`
+
+
+func groupTexts(groups []*ast.CommentGroup) string {
+	s := ""
+	for _, g := range groups {
+		for _, c := range g.List {
+			if s != "" {
+				s += " "
+			}
+			s += string(c.Text)
+		}
+	}
+	return s
+}
+
+
+func TestCommentMap(t *testing.T) {
+	file, err := parser.ParseFile("", commentsSrc, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+	f := file.Decls[0].(*ast.FuncDecl)
+	g := file.Decls[1].(*ast.FuncDecl)
+	m := NewCommentMap(file)
+	tests := []struct {
+		node  interface{}
+		texts string
+	}{
+		{f, "// f does things."},
+		{f.Body, "// done"},
+		{f.Body.List[0], "// double it"},
+		{f.Body.List[1], "// bump"},
+		{f.Body.List[2], ""},
+		{g, ""},
+	}
+	for i, test := range tests {
+		if s := groupTexts(m.Groups(test.node)); s != test.texts {
+			t.Errorf("%d: %T has comments %q, want %q", i, test.node, s, test.texts)
+		}
+	}
+
+	// copy the doubling into g, and delete the bump
+	double := f.Body.List[0]
+	call := g.Body.List[0]
+	x := Copy(double).(ast.Stmt)
+	m.Duplicate(double, x)
+	Relocate(x, call.Pos(), nil)
+	g.Body.List = []ast.Stmt{x, call}
+	f.Body.List = []ast.Stmt{double, f.Body.List[2]}
+	m.Update(file)
+
+	want := "// f does things. // double it // done // double it"
+	if s := groupTexts(file.Comments); s != want {
+		t.Errorf("comments are %q, want %q", s, want)
+	}
+	copied := file.Comments[3].List[0]
+	if copied == m.Groups(double)[0].List[0] || copied.Line != call.Pos().Line || copied.Offset >= call.Pos().Offset {
+		t.Errorf("the copied comment is at %s, want just before %s", copied.Pos(), call.Pos())
+	}
+}
+
+
+func TestCommentMapMove(t *testing.T) {
+	file, err := parser.ParseFile("", commentsSrc, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+	f := file.Decls[0].(*ast.FuncDecl)
+	m := NewCommentMap(file)
+	// replace f by a declaration at the position of g
+	var nopos token.Position
+	d := &ast.GenDecl{nil, file.Decls[1].Pos(), token.CONST, nopos, nil, nopos}
+	m.Move(f, d)
+	file.Decls = []ast.Decl{file.Decls[1], d}
+	m.Update(file)
+	if s := groupTexts(file.Comments); s != "// f does things." {
+		t.Errorf("comments are %q after the move", s)
+	}
+	if len(m.Groups(f)) != 0 || len(m.Groups(d)) != 1 {
+		t.Errorf("the comments of f were not moved")
+	}
+	if c := file.Comments[0].List[0]; c.Line != d.Pos().Line {
+		t.Errorf("the moved comment is at %s, want %s", c.Pos(), d.Pos())
+	}
+}
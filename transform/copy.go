@@ -0,0 +1,476 @@
+// Copyright 2010 David Roundy, roundyd@physics.oregonstate.edu.
+// All rights reserved.
+
+package transform
+
+import (
+	"go/ast"
+)
+
+
+func copyIdent(x *ast.Ident) *ast.Ident {
+	if x != nil {
+		return Copy(x).(*ast.Ident)
+	}
+	return nil
+}
+
+
+func copyBasicLit(x *ast.BasicLit) *ast.BasicLit {
+	if x != nil {
+		return Copy(x).(*ast.BasicLit)
+	}
+	return nil
+}
+
+
+func copyCommentGroup(g *ast.CommentGroup) *ast.CommentGroup {
+	if g != nil {
+		return Copy(g).(*ast.CommentGroup)
+	}
+	return nil
+}
+
+
+func copyFieldList(l *ast.FieldList) *ast.FieldList {
+	if l != nil {
+		return Copy(l).(*ast.FieldList)
+	}
+	return nil
+}
+
+
+func copyFuncType(t *ast.FuncType) *ast.FuncType {
+	if t != nil {
+		return Copy(t).(*ast.FuncType)
+	}
+	return nil
+}
+
+
+func copyBlockStmt(b *ast.BlockStmt) *ast.BlockStmt {
+	if b != nil {
+		return Copy(b).(*ast.BlockStmt)
+	}
+	return nil
+}
+
+
+func copyCallExpr(x *ast.CallExpr) *ast.CallExpr {
+	if x != nil {
+		return Copy(x).(*ast.CallExpr)
+	}
+	return nil
+}
+
+
+func copyExpr(x ast.Expr) ast.Expr {
+	if x != nil {
+		return Copy(x).(ast.Expr)
+	}
+	return nil
+}
+
+
+func copyStmt(s ast.Stmt) ast.Stmt {
+	if s != nil {
+		return Copy(s).(ast.Stmt)
+	}
+	return nil
+}
+
+
+// Copy returns a deep copy of an AST, so that the copy may be
+// modified without affecting the original.  Positions and comments
+// are copied along with everything else.
+//
+// Copy accepts the same node types as Walk, and panics as Walk does
+// with an UnknownNodeError for any other.
+//
+func Copy(node interface{}) interface{} {
+	switch n := node.(type) {
+	// Comments and fields
+	case *ast.Comment:
+		c := *n
+		return &c
+
+	case *ast.CommentGroup:
+		c := *n
+		c.List = make([]*ast.Comment, len(n.List))
+		for i, x := range n.List {
+			c.List[i] = Copy(x).(*ast.Comment)
+		}
+		return &c
+
+	case *ast.Field:
+		c := *n
+		c.Doc = copyCommentGroup(n.Doc)
+		c.Names = Copy(n.Names).([]*ast.Ident)
+		c.Type = copyExpr(n.Type)
+		c.Tag = copyBasicLit(n.Tag)
+		c.Comment = copyCommentGroup(n.Comment)
+		return &c
+
+	case *ast.FieldList:
+		c := *n
+		c.List = make([]*ast.Field, len(n.List))
+		for i, f := range n.List {
+			c.List[i] = Copy(f).(*ast.Field)
+		}
+		return &c
+
+	// Expressions
+	case *ast.BadExpr:
+		c := *n
+		return &c
+
+	case *ast.Ident:
+		c := *n
+		return &c
+
+	case *ast.Ellipsis:
+		c := *n
+		c.Elt = copyExpr(n.Elt)
+		return &c
+
+	case *ast.BasicLit:
+		c := *n
+		return &c
+
+	case *ast.FuncLit:
+		c := *n
+		c.Type = copyFuncType(n.Type)
+		c.Body = copyBlockStmt(n.Body)
+		return &c
+
+	case *ast.CompositeLit:
+		c := *n
+		c.Type = copyExpr(n.Type)
+		c.Elts = Copy(n.Elts).([]ast.Expr)
+		return &c
+
+	case *ast.ParenExpr:
+		c := *n
+		c.X = copyExpr(n.X)
+		return &c
+
+	case *ast.SelectorExpr:
+		c := *n
+		c.X = copyExpr(n.X)
+		c.Sel = copyIdent(n.Sel)
+		return &c
+
+	case *ast.IndexExpr:
+		c := *n
+		c.X = copyExpr(n.X)
+		c.Index = copyExpr(n.Index)
+		return &c
+
+	case *ast.SliceExpr:
+		c := *n
+		c.X = copyExpr(n.X)
+		c.Index = copyExpr(n.Index)
+		c.End = copyExpr(n.End)
+		return &c
+
+	case *ast.TypeAssertExpr:
+		c := *n
+		c.X = copyExpr(n.X)
+		c.Type = copyExpr(n.Type)
+		return &c
+
+	case *ast.CallExpr:
+		c := *n
+		c.Fun = copyExpr(n.Fun)
+		c.Args = Copy(n.Args).([]ast.Expr)
+		return &c
+
+	case *ast.StarExpr:
+		c := *n
+		c.X = copyExpr(n.X)
+		return &c
+
+	case *ast.UnaryExpr:
+		c := *n
+		c.X = copyExpr(n.X)
+		return &c
+
+	case *ast.BinaryExpr:
+		c := *n
+		c.X = copyExpr(n.X)
+		c.Y = copyExpr(n.Y)
+		return &c
+
+	case *ast.KeyValueExpr:
+		c := *n
+		c.Key = copyExpr(n.Key)
+		c.Value = copyExpr(n.Value)
+		return &c
+
+	// Types
+	case *ast.ArrayType:
+		c := *n
+		c.Len = copyExpr(n.Len)
+		c.Elt = copyExpr(n.Elt)
+		return &c
+
+	case *ast.StructType:
+		c := *n
+		c.Fields = copyFieldList(n.Fields)
+		return &c
+
+	case *ast.FuncType:
+		c := *n
+		c.Params = copyFieldList(n.Params)
+		c.Results = copyFieldList(n.Results)
+		return &c
+
+	case *ast.InterfaceType:
+		c := *n
+		c.Methods = copyFieldList(n.Methods)
+		return &c
+
+	case *ast.MapType:
+		c := *n
+		c.Key = copyExpr(n.Key)
+		c.Value = copyExpr(n.Value)
+		return &c
+
+	case *ast.ChanType:
+		c := *n
+		c.Value = copyExpr(n.Value)
+		return &c
+
+	// Statements
+	case *ast.BadStmt:
+		c := *n
+		return &c
+
+	case *ast.DeclStmt:
+		c := *n
+		c.Decl = Copy(n.Decl).(ast.Decl)
+		return &c
+
+	case *ast.EmptyStmt:
+		c := *n
+		return &c
+
+	case *ast.LabeledStmt:
+		c := *n
+		c.Label = copyIdent(n.Label)
+		c.Stmt = copyStmt(n.Stmt)
+		return &c
+
+	case *ast.ExprStmt:
+		c := *n
+		c.X = copyExpr(n.X)
+		return &c
+
+	case *ast.IncDecStmt:
+		c := *n
+		c.X = copyExpr(n.X)
+		return &c
+
+	case *ast.AssignStmt:
+		c := *n
+		c.Lhs = Copy(n.Lhs).([]ast.Expr)
+		c.Rhs = Copy(n.Rhs).([]ast.Expr)
+		return &c
+
+	case *ast.GoStmt:
+		c := *n
+		c.Call = copyCallExpr(n.Call)
+		return &c
+
+	case *ast.DeferStmt:
+		c := *n
+		c.Call = copyCallExpr(n.Call)
+		return &c
+
+	case *ast.ReturnStmt:
+		c := *n
+		c.Results = Copy(n.Results).([]ast.Expr)
+		return &c
+
+	case *ast.BranchStmt:
+		c := *n
+		c.Label = copyIdent(n.Label)
+		return &c
+
+	case *ast.BlockStmt:
+		c := *n
+		c.List = Copy(n.List).([]ast.Stmt)
+		return &c
+
+	case *ast.IfStmt:
+		c := *n
+		c.Init = copyStmt(n.Init)
+		c.Cond = copyExpr(n.Cond)
+		c.Body = copyBlockStmt(n.Body)
+		c.Else = copyStmt(n.Else)
+		return &c
+
+	case *ast.CaseClause:
+		c := *n
+		c.Values = Copy(n.Values).([]ast.Expr)
+		c.Body = Copy(n.Body).([]ast.Stmt)
+		return &c
+
+	case *ast.SwitchStmt:
+		c := *n
+		c.Init = copyStmt(n.Init)
+		c.Tag = copyExpr(n.Tag)
+		c.Body = copyBlockStmt(n.Body)
+		return &c
+
+	case *ast.TypeCaseClause:
+		c := *n
+		c.Types = Copy(n.Types).([]ast.Expr)
+		c.Body = Copy(n.Body).([]ast.Stmt)
+		return &c
+
+	case *ast.TypeSwitchStmt:
+		c := *n
+		c.Init = copyStmt(n.Init)
+		c.Assign = copyStmt(n.Assign)
+		c.Body = copyBlockStmt(n.Body)
+		return &c
+
+	case *ast.CommClause:
+		c := *n
+		c.Lhs = copyExpr(n.Lhs)
+		c.Rhs = copyExpr(n.Rhs)
+		c.Body = Copy(n.Body).([]ast.Stmt)
+		return &c
+
+	case *ast.SelectStmt:
+		c := *n
+		c.Body = copyBlockStmt(n.Body)
+		return &c
+
+	case *ast.ForStmt:
+		c := *n
+		c.Init = copyStmt(n.Init)
+		c.Cond = copyExpr(n.Cond)
+		c.Post = copyStmt(n.Post)
+		c.Body = copyBlockStmt(n.Body)
+		return &c
+
+	case *ast.RangeStmt:
+		c := *n
+		c.Key = copyExpr(n.Key)
+		c.Value = copyExpr(n.Value)
+		c.X = copyExpr(n.X)
+		c.Body = copyBlockStmt(n.Body)
+		return &c
+
+	// Declarations
+	case *ast.ImportSpec:
+		c := *n
+		c.Doc = copyCommentGroup(n.Doc)
+		c.Name = copyIdent(n.Name)
+		c.Path = copyBasicLit(n.Path)
+		c.Comment = copyCommentGroup(n.Comment)
+		return &c
+
+	case *ast.ValueSpec:
+		c := *n
+		c.Doc = copyCommentGroup(n.Doc)
+		c.Names = Copy(n.Names).([]*ast.Ident)
+		c.Type = copyExpr(n.Type)
+		c.Values = Copy(n.Values).([]ast.Expr)
+		c.Comment = copyCommentGroup(n.Comment)
+		return &c
+
+	case *ast.TypeSpec:
+		c := *n
+		c.Doc = copyCommentGroup(n.Doc)
+		c.Name = copyIdent(n.Name)
+		c.Type = copyExpr(n.Type)
+		c.Comment = copyCommentGroup(n.Comment)
+		return &c
+
+	case *ast.BadDecl:
+		c := *n
+		return &c
+
+	case *ast.GenDecl:
+		c := *n
+		c.Doc = copyCommentGroup(n.Doc)
+		c.Specs = make([]ast.Spec, len(n.Specs))
+		for i, s := range n.Specs {
+			c.Specs[i] = Copy(s).(ast.Spec)
+		}
+		return &c
+
+	case *ast.FuncDecl:
+		c := *n
+		c.Doc = copyCommentGroup(n.Doc)
+		c.Recv = copyFieldList(n.Recv)
+		c.Name = copyIdent(n.Name)
+		c.Type = copyFuncType(n.Type)
+		c.Body = copyBlockStmt(n.Body)
+		return &c
+
+	// Files and packages
+	case *ast.File:
+		c := *n
+		c.Doc = copyCommentGroup(n.Doc)
+		c.Name = copyIdent(n.Name)
+		c.Decls = Copy(n.Decls).([]ast.Decl)
+		c.Comments = make([]*ast.CommentGroup, len(n.Comments))
+		for i, g := range n.Comments {
+			c.Comments[i] = Copy(g).(*ast.CommentGroup)
+		}
+		return &c
+
+	case *ast.Package:
+		c := *n
+		c.Files = make(map[string]*ast.File)
+		for i, f := range n.Files {
+			c.Files[i] = Copy(f).(*ast.File)
+		}
+		return &c
+
+	case []*ast.Ident:
+		if n == nil {
+			return n
+		}
+		c := make([]*ast.Ident, len(n))
+		for i, x := range n {
+			c[i] = Copy(x).(*ast.Ident)
+		}
+		return c
+
+	case []ast.Expr:
+		if n == nil {
+			return n
+		}
+		c := make([]ast.Expr, len(n))
+		for i, x := range n {
+			c[i] = copyExpr(x)
+		}
+		return c
+
+	case []ast.Stmt:
+		if n == nil {
+			return n
+		}
+		c := make([]ast.Stmt, len(n))
+		for i, x := range n {
+			c[i] = copyStmt(x)
+		}
+		return c
+
+	case []ast.Decl:
+		if n == nil {
+			return n
+		}
+		c := make([]ast.Decl, len(n))
+		for i, x := range n {
+			c[i] = Copy(x).(ast.Decl)
+		}
+		return c
+	}
+	return nodeType(node).Copy(node)
+}
@@ -0,0 +1,182 @@
+// Copyright 2010 David Roundy, roundyd@physics.oregonstate.edu.
+// All rights reserved.
+
+package transform
+
+import (
+	"bytes"
+	"container/vector"
+	"fmt"
+	"go/ast"
+	"go/printer"
+	"go/token"
+	"reflect"
+	"strings"
+)
+
+
+// The mode of Equal and Diff.
+const (
+	CompareComments = 1 << iota // compare comments too
+)
+
+
+// Equal returns true if a and b are structurally equal, which is to
+// say that they differ at most in positions, and in comments unless
+// mode includes CompareComments.  Each may be of any type accepted by
+// Walk, other than a slice.
+func Equal(a, b interface{}, mode uint) bool {
+	d := differ{mode: mode, max: 1}
+	d.node("", a, b)
+	return d.diffs.Len() == 0
+}
+
+
+// A Difference records a place where two ASTs differ.
+type Difference struct {
+	Path string // the fields and indices leading to the difference, such as ".Decls[1].Body"
+	A, B string // short descriptions of the two differing values
+}
+
+
+func (d *Difference) String() string {
+	path := d.Path
+	if path == "" {
+		path = "."
+	}
+	return path + ": " + d.A + " != " + d.B
+}
+
+
+// Diff returns the places where a and b differ, in the order in which
+// Walk reaches them, comparing them as Equal does.  Where two lists
+// differ in length, the elements of the longer with no counterpart in
+// the shorter are reported as missing from the shorter.
+func Diff(a, b interface{}, mode uint) []*Difference {
+	d := differ{mode: mode}
+	d.node("", a, b)
+	diffs := make([]*Difference, d.diffs.Len())
+	for i, x := range d.diffs {
+		diffs[i] = x.(*Difference)
+	}
+	return diffs
+}
+
+
+type differ struct {
+	mode  uint
+	max   int // stop after max differences, if max is not zero
+	diffs vector.Vector
+}
+
+
+func (d *differ) done() bool { return d.max > 0 && d.diffs.Len() >= d.max }
+
+
+func (d *differ) add(path string, a, b interface{}) {
+	d.diffs.Push(&Difference{path, describe(a), describe(b)})
+}
+
+
+func (d *differ) node(path string, a, b interface{}) {
+	if d.done() {
+		return
+	}
+	if a == nil || b == nil {
+		if a != nil || b != nil {
+			d.add(path, a, b)
+		}
+		return
+	}
+	if reflect.Typeof(a) != reflect.Typeof(b) {
+		d.add(path, a, b)
+		return
+	}
+	af, bf := fields(a), fields(b)
+	if len(af) != len(bf) {
+		d.add(path, fieldNames(af), fieldNames(bf))
+		return
+	}
+	for i, f := range af {
+		if f.name != bf[i].name {
+			d.add(path, fieldNames(af), fieldNames(bf))
+			return
+		}
+	}
+	for i, f := range af {
+		if f.isComment() && d.mode&CompareComments == 0 {
+			continue
+		}
+		d.value(path+"."+f.name, f.value, bf[i].value)
+	}
+}
+
+
+func (d *differ) value(path string, a, b interface{}) {
+	switch a := a.(type) {
+	case []interface{}:
+		b := b.([]interface{})
+		for i := 0; i < len(a) || i < len(b); i++ {
+			p := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case d.done():
+				return
+			case i >= len(a):
+				d.add(p, missing{}, b[i])
+			case i >= len(b):
+				d.add(p, a[i], missing{})
+			default:
+				d.node(p, a[i], b[i])
+			}
+		}
+	case token.Token, ast.ChanDir, string, bool:
+		if a != b && !d.done() {
+			d.add(path, a, b)
+		}
+	default:
+		d.node(path, a, b)
+	}
+}
+
+
+// missing stands for a list element with no counterpart.
+type missing struct{}
+
+
+// fieldNames describes a node by the names of its fields.
+type fieldNames []field
+
+
+// describe returns a short description of x, which is a node, a
+// scalar, a fieldNames or a missing.
+func describe(x interface{}) string {
+	switch x := x.(type) {
+	case nil:
+		return "nil"
+	case missing:
+		return "(missing)"
+	case fieldNames:
+		names := make([]string, len(x))
+		for i, f := range x {
+			names[i] = f.name
+		}
+		return "{" + strings.Join(names, ", ") + "}"
+	case string:
+		return fmt.Sprintf("%q", x)
+	case token.Token, ast.ChanDir, bool:
+		return fmt.Sprint(x)
+	case ast.Expr, ast.Stmt, ast.Decl:
+		// printed below
+	default:
+		return fmt.Sprintf("%T", x)
+	}
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, x); err != nil || buf.Len() == 0 {
+		return fmt.Sprintf("%T", x)
+	}
+	s := buf.String()
+	if nl := strings.Index(s, "\n"); nl >= 0 {
+		s = s[0:nl] + " ..."
+	}
+	return fmt.Sprintf("%T %s", x, s)
+}
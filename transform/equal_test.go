@@ -0,0 +1,62 @@
+// Copyright 2010 David Roundy, roundyd@physics.oregonstate.edu.
+// All rights reserved.
+
+package transform
+
+import (
+	"testing"
+	"github.com/droundy/go-crazy/parser"
+)
+
+
+const equalSrc = `package p
+
+// f adds.
+func f(a, b int) int {
+	return a + b
+}
+`
+
+
+var equalTests = []struct {
+	src      string
+	equal    bool // with comments ignored
+	comments bool // with comments compared
+	diff     string
+}{
+	{equalSrc, true, true, ""},
+	{"package p\n\n\n// f adds.\nfunc f(a, b int) int { return a+b }", true, true, ""},
+	{"package p\nfunc f(a, b int) int { return a + b }", true, false, ".Decls[0].Doc: *ast.CommentGroup != nil"},
+	{"package p\nfunc f(a, b int) int { return a - b }", false, false, ".Decls[0].Body.List[0].Results[0].Op: + != -"},
+	{"package p\nfunc f(a, c int) int { return a + b }", false, false, `.Decls[0].Type.Params.List[0].Names[1].Name: "b" != "c"`},
+	{"package p\nfunc f(a, b int) int { return a + b; panic(0) }", false, false, ".Decls[0].Body.List[1]: (missing) != *ast.ExprStmt panic(0)"},
+	{"package p\nfunc f(a, b int) int { return (a + b) }", false, false, ".Decls[0].Body.List[0].Results[0]: *ast.BinaryExpr a + b != *ast.ParenExpr (a + b)"},
+}
+
+
+func TestEqual(t *testing.T) {
+	a, err := parser.ParseFile("", equalSrc, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+	for _, test := range equalTests {
+		b, err := parser.ParseFile("", test.src, parser.ParseComments)
+		if err != nil {
+			t.Fatalf("ParseFile(%q): %v", test.src, err)
+		}
+		if Equal(a, b, 0) != test.equal {
+			t.Errorf("Equal(%q) = %v, want %v", test.src, !test.equal, test.equal)
+		}
+		if Equal(a, b, CompareComments) != test.comments {
+			t.Errorf("Equal(%q, CompareComments) = %v, want %v", test.src, !test.comments, test.comments)
+		}
+		diffs := Diff(a, b, CompareComments)
+		if test.diff == "" {
+			if len(diffs) != 0 {
+				t.Errorf("Diff(%q) = %v, want none", test.src, diffs)
+			}
+		} else if len(diffs) == 0 || diffs[0].String() != test.diff {
+			t.Errorf("Diff(%q) = %v, want %s", test.src, diffs, test.diff)
+		}
+	}
+}
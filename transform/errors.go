@@ -0,0 +1,77 @@
+// Copyright 2010 David Roundy, roundyd@physics.oregonstate.edu.
+// All rights reserved.
+
+package transform
+
+import (
+	"fmt"
+	"go/token"
+	"os"
+	"github.com/droundy/go-crazy/scanner"
+)
+
+
+// An ErrorVisitor is a Visitor that may find problems in the code it
+// visits, such as a function that it cannot inline.  Rather than panic
+// or skip the problem silently, it reports the problem to errs, which
+// it may also ask to abort the walk.
+type ErrorVisitor interface {
+	Visit(node interface{}, errs *Errors) (modifiednode interface{})
+}
+
+
+// Errors collects the errors reported by ErrorVisitors.  The zero value
+// for Errors is ready to use.
+type Errors struct {
+	scanner.ErrorVector
+	aborted bool
+}
+
+
+// Errorf reports an error at pos, formatted as by fmt.Sprintf.
+func (e *Errors) Errorf(pos token.Position, format string, args ...interface{}) {
+	e.Error(pos, fmt.Sprintf(format, args...))
+}
+
+
+// Abort stops the walks reporting to e.  No more nodes are visited,
+// and every node not yet visited is left as it is.
+func (e *Errors) Abort() { e.aborted = true }
+
+
+// Aborted returns true if Abort has been called.
+func (e *Errors) Aborted() bool { return e.aborted }
+
+
+// Walk walks node as Walk does, with v reporting to e.  An
+// ErrorVisitor calls it to walk the children of the node it visits.
+func (e *Errors) Walk(v ErrorVisitor, node interface{}) (modifiednode interface{}) {
+	return Walk(errorVisitor{v, e}, node)
+}
+
+
+// WalkErrors walks node with v, and returns the result together with
+// the errors v reported, sorted by position, or nil if there were
+// none.  If the walk meets a node of an unknown type, WalkErrors
+// returns the UnknownNodeError instead.
+func WalkErrors(v ErrorVisitor, node interface{}) (modifiednode interface{}, err os.Error) {
+	defer CatchUnknownNode(&err)
+	e := new(Errors)
+	modifiednode = e.Walk(v, node)
+	return modifiednode, e.GetError(scanner.Sorted)
+}
+
+
+// An errorVisitor adapts an ErrorVisitor to Walk.
+type errorVisitor struct {
+	v    ErrorVisitor
+	errs *Errors
+}
+
+
+func (v errorVisitor) Visit(node interface{}) interface{} {
+	if v.errs.aborted {
+		return node // don't descend
+	}
+	return v.v.Visit(node, v.errs)
+}
@@ -0,0 +1,93 @@
+// Copyright 2010 David Roundy, roundyd@physics.oregonstate.edu.
+// All rights reserved.
+
+package transform
+
+import (
+	"go/ast"
+	"testing"
+	"github.com/droundy/go-crazy/parser"
+	"github.com/droundy/go-crazy/scanner"
+)
+
+
+const errorsSrc = `package p
+
+func f() {
+	panic(1)
+	g(panic(2))
+	panic(3)
+}
+
+func g(x int) {
+	panic(4)
+}
+`
+
+
+// A panicFinder reports each call to panic as an error, and aborts
+// after max of them.
+type panicFinder struct {
+	max int
+}
+
+
+func (v *panicFinder) Visit(node interface{}, errs *Errors) interface{} {
+	switch n := node.(type) {
+	case *ast.CallExpr:
+		switch n.Fun.(*ast.Ident).Name {
+		case "panic":
+			errs.Errorf(n.Pos(), "call to panic with %s", n.Args[0].(*ast.BasicLit).Value)
+			if errs.ErrorCount() == v.max {
+				errs.Abort()
+			}
+		case "g":
+			// visit the arguments before the call
+			n.Args = errs.Walk(v, n.Args).([]ast.Expr)
+			return n
+		}
+	}
+	return nil
+}
+
+
+var walkErrorsTests = []struct {
+	max    int
+	errors []string
+}{
+	{0, []string{"4:2: call to panic with 1", "5:4: call to panic with 2", "6:2: call to panic with 3", "10:2: call to panic with 4"}},
+	{2, []string{"4:2: call to panic with 1", "5:4: call to panic with 2"}},
+	{3, []string{"4:2: call to panic with 1", "5:4: call to panic with 2", "6:2: call to panic with 3"}},
+}
+
+
+func TestWalkErrors(t *testing.T) {
+	for _, test := range walkErrorsTests {
+		f, err := parser.ParseFile("", errorsSrc, 0)
+		if err != nil {
+			t.Fatalf("ParseFile: %v", err)
+		}
+		_, err = WalkErrors(&panicFinder{test.max}, f)
+		list, _ := err.(scanner.ErrorList)
+		if len(list) != len(test.errors) {
+			t.Errorf("with max %d, got errors %v, want %v", test.max, err, test.errors)
+			continue
+		}
+		for i, e := range list {
+			if e.String() != test.errors[i] {
+				t.Errorf("with max %d, error %d is %q, want %q", test.max, i, e, test.errors[i])
+			}
+		}
+	}
+}
+
+
+func TestWalkErrorsNone(t *testing.T) {
+	f, err := parser.ParseFile("", "package p\nfunc f() { g(1) }", 0)
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+	if _, err := WalkErrors(&panicFinder{0}, f); err != nil {
+		t.Errorf("WalkErrors reported %v", err)
+	}
+}
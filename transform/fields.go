@@ -0,0 +1,356 @@
+// Copyright 2010 David Roundy, roundyd@physics.oregonstate.edu.
+// All rights reserved.
+
+package transform
+
+import (
+	"fmt"
+	"go/ast"
+	"reflect"
+	"sort"
+)
+
+
+// A field is a named part of an AST node: a child node, a list of
+// child nodes, or a scalar such as an operator, a name or a literal.
+type field struct {
+	name  string
+	value interface{}
+}
+
+
+// fields returns the parts of node other than its positions, in the
+// order in which Walk visits them.  An absent child is nil, a list is
+// given as an []interface{}, and each scalar is a token.Token,
+// ast.ChanDir, string or bool.  The files of a Package are given as
+// one field each, in order of their names, and the children of a node
+// of a registered type as one list, named Children.
+func fields(node interface{}) []field {
+	switch n := node.(type) {
+	// Comments and fields
+	case *ast.Comment:
+		return []field{{"Text", string(n.Text)}}
+
+	case *ast.CommentGroup:
+		return []field{{"List", commentList(n.List)}}
+
+	case *ast.Field:
+		return []field{
+			{"Doc", orNil(n.Doc)},
+			{"Names", identList(n.Names)},
+			{"Type", orNil(n.Type)},
+			{"Tag", orNil(n.Tag)},
+			{"Comment", orNil(n.Comment)},
+		}
+
+	case *ast.FieldList:
+		return []field{{"List", fieldList(n.List)}}
+
+	// Expressions
+	case *ast.BadExpr:
+		return nil
+
+	case *ast.Ident:
+		return []field{{"Name", n.Name}}
+
+	case *ast.Ellipsis:
+		return []field{{"Elt", orNil(n.Elt)}}
+
+	case *ast.BasicLit:
+		return []field{{"Kind", n.Kind}, {"Value", string(n.Value)}}
+
+	case *ast.FuncLit:
+		return []field{{"Type", orNil(n.Type)}, {"Body", orNil(n.Body)}}
+
+	case *ast.CompositeLit:
+		return []field{{"Type", orNil(n.Type)}, {"Elts", exprList(n.Elts)}}
+
+	case *ast.ParenExpr:
+		return []field{{"X", orNil(n.X)}}
+
+	case *ast.SelectorExpr:
+		return []field{{"X", orNil(n.X)}, {"Sel", orNil(n.Sel)}}
+
+	case *ast.IndexExpr:
+		return []field{{"X", orNil(n.X)}, {"Index", orNil(n.Index)}}
+
+	case *ast.SliceExpr:
+		return []field{{"X", orNil(n.X)}, {"Index", orNil(n.Index)}, {"End", orNil(n.End)}}
+
+	case *ast.TypeAssertExpr:
+		return []field{{"X", orNil(n.X)}, {"Type", orNil(n.Type)}}
+
+	case *ast.CallExpr:
+		return []field{
+			{"Fun", orNil(n.Fun)},
+			{"Args", exprList(n.Args)},
+			{"Ellipsis", n.Ellipsis.IsValid()},
+		}
+
+	case *ast.StarExpr:
+		return []field{{"X", orNil(n.X)}}
+
+	case *ast.UnaryExpr:
+		return []field{{"Op", n.Op}, {"X", orNil(n.X)}}
+
+	case *ast.BinaryExpr:
+		return []field{{"X", orNil(n.X)}, {"Op", n.Op}, {"Y", orNil(n.Y)}}
+
+	case *ast.KeyValueExpr:
+		return []field{{"Key", orNil(n.Key)}, {"Value", orNil(n.Value)}}
+
+	// Types
+	case *ast.ArrayType:
+		return []field{{"Len", orNil(n.Len)}, {"Elt", orNil(n.Elt)}}
+
+	case *ast.StructType:
+		return []field{{"Fields", orNil(n.Fields)}}
+
+	case *ast.FuncType:
+		return []field{{"Params", orNil(n.Params)}, {"Results", orNil(n.Results)}}
+
+	case *ast.InterfaceType:
+		return []field{{"Methods", orNil(n.Methods)}}
+
+	case *ast.MapType:
+		return []field{{"Key", orNil(n.Key)}, {"Value", orNil(n.Value)}}
+
+	case *ast.ChanType:
+		return []field{{"Dir", n.Dir}, {"Value", orNil(n.Value)}}
+
+	// Statements
+	case *ast.BadStmt, *ast.EmptyStmt:
+		return nil
+
+	case *ast.DeclStmt:
+		return []field{{"Decl", orNil(n.Decl)}}
+
+	case *ast.LabeledStmt:
+		return []field{{"Label", orNil(n.Label)}, {"Stmt", orNil(n.Stmt)}}
+
+	case *ast.ExprStmt:
+		return []field{{"X", orNil(n.X)}}
+
+	case *ast.IncDecStmt:
+		return []field{{"X", orNil(n.X)}, {"Tok", n.Tok}}
+
+	case *ast.AssignStmt:
+		return []field{{"Lhs", exprList(n.Lhs)}, {"Tok", n.Tok}, {"Rhs", exprList(n.Rhs)}}
+
+	case *ast.GoStmt:
+		return []field{{"Call", orNil(n.Call)}}
+
+	case *ast.DeferStmt:
+		return []field{{"Call", orNil(n.Call)}}
+
+	case *ast.ReturnStmt:
+		return []field{{"Results", exprList(n.Results)}}
+
+	case *ast.BranchStmt:
+		return []field{{"Tok", n.Tok}, {"Label", orNil(n.Label)}}
+
+	case *ast.BlockStmt:
+		return []field{{"List", stmtList(n.List)}}
+
+	case *ast.IfStmt:
+		return []field{
+			{"Init", orNil(n.Init)},
+			{"Cond", orNil(n.Cond)},
+			{"Body", orNil(n.Body)},
+			{"Else", orNil(n.Else)},
+		}
+
+	case *ast.CaseClause:
+		return []field{{"Values", exprList(n.Values)}, {"Body", stmtList(n.Body)}}
+
+	case *ast.SwitchStmt:
+		return []field{{"Init", orNil(n.Init)}, {"Tag", orNil(n.Tag)}, {"Body", orNil(n.Body)}}
+
+	case *ast.TypeCaseClause:
+		return []field{{"Types", exprList(n.Types)}, {"Body", stmtList(n.Body)}}
+
+	case *ast.TypeSwitchStmt:
+		return []field{{"Init", orNil(n.Init)}, {"Assign", orNil(n.Assign)}, {"Body", orNil(n.Body)}}
+
+	case *ast.CommClause:
+		return []field{
+			{"Tok", n.Tok},
+			{"Lhs", orNil(n.Lhs)},
+			{"Rhs", orNil(n.Rhs)},
+			{"Body", stmtList(n.Body)},
+		}
+
+	case *ast.SelectStmt:
+		return []field{{"Body", orNil(n.Body)}}
+
+	case *ast.ForStmt:
+		return []field{
+			{"Init", orNil(n.Init)},
+			{"Cond", orNil(n.Cond)},
+			{"Post", orNil(n.Post)},
+			{"Body", orNil(n.Body)},
+		}
+
+	case *ast.RangeStmt:
+		return []field{
+			{"Key", orNil(n.Key)},
+			{"Value", orNil(n.Value)},
+			{"Tok", n.Tok},
+			{"X", orNil(n.X)},
+			{"Body", orNil(n.Body)},
+		}
+
+	// Declarations
+	case *ast.ImportSpec:
+		return []field{
+			{"Doc", orNil(n.Doc)},
+			{"Name", orNil(n.Name)},
+			{"Path", orNil(n.Path)},
+			{"Comment", orNil(n.Comment)},
+		}
+
+	case *ast.ValueSpec:
+		return []field{
+			{"Doc", orNil(n.Doc)},
+			{"Names", identList(n.Names)},
+			{"Type", orNil(n.Type)},
+			{"Values", exprList(n.Values)},
+			{"Comment", orNil(n.Comment)},
+		}
+
+	case *ast.TypeSpec:
+		return []field{
+			{"Doc", orNil(n.Doc)},
+			{"Name", orNil(n.Name)},
+			{"Type", orNil(n.Type)},
+			{"Comment", orNil(n.Comment)},
+		}
+
+	case *ast.BadDecl:
+		return nil
+
+	case *ast.GenDecl:
+		return []field{{"Doc", orNil(n.Doc)}, {"Tok", n.Tok}, {"Specs", specList(n.Specs)}}
+
+	case *ast.FuncDecl:
+		return []field{
+			{"Doc", orNil(n.Doc)},
+			{"Recv", orNil(n.Recv)},
+			{"Name", orNil(n.Name)},
+			{"Type", orNil(n.Type)},
+			{"Body", orNil(n.Body)},
+		}
+
+	// Files and packages
+	case *ast.File:
+		return []field{
+			{"Doc", orNil(n.Doc)},
+			{"Name", orNil(n.Name)},
+			{"Decls", declList(n.Decls)},
+			{"Comments", groupList(n.Comments)},
+		}
+
+	case *ast.Package:
+		names := make([]string, len(n.Files))
+		i := 0
+		for name := range n.Files {
+			names[i] = name
+			i++
+		}
+		sort.SortStrings(names)
+		fs := make([]field, 1+len(names))
+		fs[0] = field{"Name", n.Name}
+		for i, name := range names {
+			fs[1+i] = field{fmt.Sprintf("Files[%q]", name), n.Files[name]}
+		}
+		return fs
+	}
+	return []field{{"Children", children(node)}}
+}
+
+
+// isComment returns true if f holds comments rather than code.
+func (f field) isComment() bool {
+	return f.name == "Doc" || f.name == "Comment" || f.name == "Comments"
+}
+
+
+// orNil returns node, or nil if node is a nil pointer.
+func orNil(node interface{}) interface{} {
+	if p, ok := reflect.NewValue(node).(*reflect.PtrValue); ok && p.IsNil() {
+		return nil
+	}
+	return node
+}
+
+
+func commentList(l []*ast.Comment) []interface{} {
+	out := make([]interface{}, len(l))
+	for i, x := range l {
+		out[i] = x
+	}
+	return out
+}
+
+
+func groupList(l []*ast.CommentGroup) []interface{} {
+	out := make([]interface{}, len(l))
+	for i, x := range l {
+		out[i] = x
+	}
+	return out
+}
+
+
+func fieldList(l []*ast.Field) []interface{} {
+	out := make([]interface{}, len(l))
+	for i, x := range l {
+		out[i] = x
+	}
+	return out
+}
+
+
+func identList(l []*ast.Ident) []interface{} {
+	out := make([]interface{}, len(l))
+	for i, x := range l {
+		out[i] = x
+	}
+	return out
+}
+
+
+func exprList(l []ast.Expr) []interface{} {
+	out := make([]interface{}, len(l))
+	for i, x := range l {
+		out[i] = x
+	}
+	return out
+}
+
+
+func stmtList(l []ast.Stmt) []interface{} {
+	out := make([]interface{}, len(l))
+	for i, x := range l {
+		out[i] = x
+	}
+	return out
+}
+
+
+func specList(l []ast.Spec) []interface{} {
+	out := make([]interface{}, len(l))
+	for i, x := range l {
+		out[i] = x
+	}
+	return out
+}
+
+
+func declList(l []ast.Decl) []interface{} {
+	out := make([]interface{}, len(l))
+	for i, x := range l {
+		out[i] = x
+	}
+	return out
+}
@@ -0,0 +1,93 @@
+// Copyright 2010 David Roundy, roundyd@physics.oregonstate.edu.
+// All rights reserved.
+
+package transform
+
+import (
+	"fmt"
+	"go/ast"
+)
+
+
+// A Gensym generates identifiers that are guaranteed not to collide
+// with any identifier in the ASTs it was created for, nor with any
+// identifier it has generated before.  Transforms should use a
+// Gensym rather than inventing names from fixed strings.
+type Gensym struct {
+	used map[string]bool
+}
+
+
+// NewGensym returns a Gensym avoiding every identifier used within
+// the given nodes, which may be of any type accepted by Walk.
+func NewGensym(nodes ...interface{}) *Gensym {
+	g := &Gensym{make(map[string]bool)}
+	for _, n := range nodes {
+		g.Reserve(n)
+	}
+	return g
+}
+
+
+// Reserve marks every identifier used within node as taken.
+func (g *Gensym) Reserve(node interface{}) { Walk(identCollector(g.used), node) }
+
+
+// Used returns true if name is taken.
+func (g *Gensym) Used(name string) bool { return g.used[name] }
+
+
+// Name returns an unused name, which is base itself if possible and
+// otherwise base with a numeric suffix, and marks it as taken.
+func (g *Gensym) Name(base string) string {
+	name := base
+	for i := 1; g.used[name]; i++ {
+		name = fmt.Sprint(base, "_", i)
+	}
+	g.used[name] = true
+	return name
+}
+
+
+// Ident is like Name, but returns a new identifier.
+func (g *Gensym) Ident(base string) *ast.Ident { return ast.NewIdent(g.Name(base)) }
+
+
+type identCollector map[string]bool
+
+func (v identCollector) Visit(node interface{}) interface{} {
+	if id, ok := node.(*ast.Ident); ok {
+		v[id.Name] = true
+	}
+	return nil
+}
+
+
+// AlphaRename gives every identifier declared locally within node a
+// fresh name from g, and renames the references to it accordingly,
+// so that node may be spliced into another scope without capturing
+// or shadowing its names.  The parameters and results of functions
+// within node are renamed too, but labels, struct fields and the
+// names of methods are not.  The identifiers within node are
+// reserved in g first.
+//
+// Names are matched to their declarations by Resolve, so keys in
+// composite literals are taken to be field names unless the literal's
+// type is a map or array type.
+//
+// AlphaRename modifies node in place and returns it.  The top-level
+// declarations of a File are not local, so node should be a function
+// declaration, a function literal, or a statement.
+//
+func AlphaRename(node interface{}, g *Gensym) interface{} {
+	g.Reserve(node)
+	r := Resolve(node)
+	names := make(map[*ast.Ident]string)
+	for _, id := range r.Decls() {
+		names[id] = g.Name(id.Name)
+	}
+	for id, decl := range r.refs {
+		id.Name = names[decl]
+	}
+	return node
+}
@@ -0,0 +1,73 @@
+// Copyright 2010 David Roundy, roundyd@physics.oregonstate.edu.
+// All rights reserved.
+
+package transform
+
+import (
+	"bytes"
+	"go/ast"
+	"go/printer"
+	"strings"
+	"testing"
+	"github.com/droundy/go-crazy/parser"
+)
+
+
+func TestGensym(t *testing.T) {
+	f, err := parser.ParseFile("", "package p; var x, x_1 int", 0)
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+	g := NewGensym(f)
+	if name := g.Name("y"); name != "y" {
+		t.Errorf("Name(y) = %s, want y", name)
+	}
+	if name := g.Name("y"); name != "y_1" {
+		t.Errorf("second Name(y) = %s, want y_1", name)
+	}
+	if name := g.Name("x"); name != "x_2" {
+		t.Errorf("Name(x) = %s, want x_2", name)
+	}
+}
+
+
+const renameSrc = `package p
+
+type T struct { x int }
+
+func f(x int) int {
+	y := x
+	if x := y; x > 0 {
+		return T{x: x}.x
+	}
+	return y + x
+}
+`
+
+
+var renamed = []string{
+	"func f(x_1 int) int",
+	"y_1 := x_1",
+	"if x_2 := y_1; x_2 > 0",
+	"T{x: x_2}.x",
+	"y_1 + x_1",
+}
+
+
+func TestAlphaRename(t *testing.T) {
+	f, err := parser.ParseFile("", renameSrc, 0)
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+	decl := f.Decls[1].(*ast.FuncDecl)
+	AlphaRename(decl, NewGensym(f))
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, decl); err != nil {
+		t.Fatalf("Fprint: %v", err)
+	}
+	for _, s := range renamed {
+		if strings.Index(buf.String(), s) < 0 {
+			t.Errorf("expected %q in renamed function:\n%s", s, buf.String())
+		}
+	}
+}
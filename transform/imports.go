@@ -0,0 +1,246 @@
+// Copyright 2010 David Roundy, roundyd@physics.oregonstate.edu.
+// All rights reserved.
+
+package transform
+
+import (
+	"container/vector"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+
+// Imports returns the names under which f imports packages, mapped
+// to their import paths.
+func Imports(f *ast.File) map[string]string {
+	paths := make(map[string]string)
+	for _, s := range importSpecs(f) {
+		paths[ImportName(s)] = ImportPath(s)
+	}
+	return paths
+}
+
+
+// importSpecs returns the import specs of f, in order.
+func importSpecs(f *ast.File) []*ast.ImportSpec {
+	var specs vector.Vector
+	for _, d := range f.Decls {
+		if g, ok := d.(*ast.GenDecl); ok && g.Tok == token.IMPORT {
+			for _, s := range g.Specs {
+				specs.Push(s)
+			}
+		}
+	}
+	list := make([]*ast.ImportSpec, specs.Len())
+	for i, s := range specs {
+		list[i] = s.(*ast.ImportSpec)
+	}
+	return list
+}
+
+
+// ImportPath returns the path imported by s.
+func ImportPath(s *ast.ImportSpec) string {
+	path, err := strconv.Unquote(string(s.Path.Value))
+	if err != nil {
+		return string(s.Path.Value)
+	}
+	return path
+}
+
+
+// ImportName returns the name under which s imports its package,
+// which may be "_" or ".".
+func ImportName(s *ast.ImportSpec) string {
+	if s.Name != nil {
+		return s.Name.Name
+	}
+	return DefaultImportName(ImportPath(s))
+}
+
+
+// DefaultImportName returns the name under which path is imported
+// unless it is given another.
+func DefaultImportName(path string) string {
+	return path[strings.LastIndex(path, "/")+1:]
+}
+
+
+// AddImport makes sure that f imports path, and returns the name
+// under which it does so.  A package newly imported is renamed if its
+// name is already that of another import or of a declaration at the
+// top level of f.
+func AddImport(f *ast.File, path string) string {
+	paths := Imports(f)
+	for name, p := range paths {
+		if p == path && name != "_" && name != "." {
+			return name
+		}
+	}
+	taken := topLevelNames(f)
+	for name := range paths {
+		taken[name] = true
+	}
+	name := DefaultImportName(path)
+	var ident *ast.Ident
+	for i := 1; taken[name]; i++ {
+		name = fmt.Sprint(DefaultImportName(path), "_", i)
+		ident = ast.NewIdent(name)
+	}
+	var nopos token.Position
+	spec := &ast.ImportSpec{nil, ident, &ast.BasicLit{nopos, token.STRING, []byte(strconv.Quote(path))}, nil}
+
+	for _, d := range f.Decls {
+		if g, ok := d.(*ast.GenDecl); ok && g.Tok == token.IMPORT {
+			specs := make([]ast.Spec, len(g.Specs)+1)
+			copy(specs, g.Specs)
+			specs[len(g.Specs)] = spec
+			g.Specs = specs
+			if !g.Lparen.IsValid() {
+				// the printer only parenthesizes declarations
+				// that had parentheses to begin with
+				g.Lparen = g.Pos()
+				g.Rparen = g.Pos()
+			}
+			return name
+		}
+	}
+	decls := make([]ast.Decl, len(f.Decls)+1)
+	decls[0] = &ast.GenDecl{nil, f.Pos(), token.IMPORT, nopos, []ast.Spec{spec}, nopos}
+	copy(decls[1:], f.Decls)
+	f.Decls = decls
+	return name
+}
+
+
+// topLevelNames returns the names declared at the top level of f,
+// other than by imports.
+func topLevelNames(f *ast.File) map[string]bool {
+	names := make(map[string]bool)
+	for _, d := range f.Decls {
+		switch d := d.(type) {
+		case *ast.FuncDecl:
+			if d.Recv == nil {
+				names[d.Name.Name] = true
+			}
+		case *ast.GenDecl:
+			for _, s := range d.Specs {
+				switch s := s.(type) {
+				case *ast.TypeSpec:
+					names[s.Name.Name] = true
+				case *ast.ValueSpec:
+					for _, id := range s.Names {
+						names[id.Name] = true
+					}
+				}
+			}
+		}
+	}
+	return names
+}
+
+
+// DeleteImport removes every import of path from f, and returns true
+// if there was one.
+func DeleteImport(f *ast.File, path string) bool {
+	return deleteImports(f, func(s *ast.ImportSpec) bool { return ImportPath(s) == path }) > 0
+}
+
+
+// deleteImports removes the import specs of f for which del returns
+// true, together with the declarations they leave empty, and returns
+// how many it removed.
+func deleteImports(f *ast.File, del func(s *ast.ImportSpec) bool) int {
+	deleted := 0
+	var decls vector.Vector
+	for _, d := range f.Decls {
+		g, ok := d.(*ast.GenDecl)
+		if !ok || g.Tok != token.IMPORT {
+			decls.Push(d)
+			continue
+		}
+		var specs vector.Vector
+		for _, s := range g.Specs {
+			if del(s.(*ast.ImportSpec)) {
+				deleted++
+			} else {
+				specs.Push(s)
+			}
+		}
+		if specs.Len() == 0 {
+			continue
+		}
+		g.Specs = make([]ast.Spec, specs.Len())
+		for i, s := range specs {
+			g.Specs[i] = s.(ast.Spec)
+		}
+		decls.Push(g)
+	}
+	if deleted > 0 {
+		f.Decls = make([]ast.Decl, decls.Len())
+		for i, d := range decls {
+			f.Decls[i] = d.(ast.Decl)
+		}
+	}
+	return deleted
+}
+
+
+// UnusedImports returns the import specs of f whose package is never
+// referred to within f.  Imports named "_" or "." are always in use,
+// since their effects can't be seen in f.
+func UnusedImports(f *ast.File) []*ast.ImportSpec {
+	used := usedPackages(f)
+	var unused vector.Vector
+	for _, s := range importSpecs(f) {
+		if name := ImportName(s); name != "_" && name != "." && !used[name] {
+			unused.Push(s)
+		}
+	}
+	list := make([]*ast.ImportSpec, unused.Len())
+	for i, s := range unused {
+		list[i] = s.(*ast.ImportSpec)
+	}
+	return list
+}
+
+
+// usedPackages returns the names used as package qualifiers in f,
+// that is, the names qualified by a selector that are not declared
+// within the package.
+func usedPackages(f *ast.File) map[string]bool {
+	r := Resolve(f)
+	used := make(map[string]bool)
+	Apply(f, func(c *Cursor) bool {
+		if sel, ok := c.Node().(*ast.SelectorExpr); ok {
+			if x, ok := sel.X.(*ast.Ident); ok && r.Decl(x) == nil {
+				used[x.Name] = true
+			}
+		}
+		return true
+	}, nil)
+	return used
+}
+
+
+// DeleteUnusedImports removes the unused imports of f, as found by
+// UnusedImports, and returns their paths.
+func DeleteUnusedImports(f *ast.File) []string {
+	unused := UnusedImports(f)
+	paths := make([]string, len(unused))
+	for i, s := range unused {
+		paths[i] = ImportPath(s)
+	}
+	deleteImports(f, func(s *ast.ImportSpec) bool {
+		for _, u := range unused {
+			if s == u {
+				return true
+			}
+		}
+		return false
+	})
+	return paths
+}
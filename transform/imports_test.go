@@ -0,0 +1,82 @@
+// Copyright 2010 David Roundy, roundyd@physics.oregonstate.edu.
+// All rights reserved.
+
+package transform
+
+import (
+	"testing"
+	"github.com/droundy/go-crazy/parser"
+)
+
+
+const importsSrc = `package p
+
+import (
+	"fmt"
+	"os"
+	str "strings"
+	_ "http"
+)
+
+var math = 3
+
+func f(fmt int) {
+	os.Exit(fmt)
+}
+`
+
+
+func TestImports(t *testing.T) {
+	f, err := parser.ParseFile("", importsSrc, 0)
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+	paths := Imports(f)
+	if len(paths) != 4 || paths["str"] != "strings" || paths["_"] != "http" {
+		t.Errorf("Imports = %v", paths)
+	}
+
+	// fmt is shadowed by a parameter, and str is never used
+	unused := UnusedImports(f)
+	if len(unused) != 2 || ImportPath(unused[0]) != "fmt" || ImportPath(unused[1]) != "strings" {
+		t.Errorf("%d unused imports, want fmt and strings", len(unused))
+	}
+
+	adds := []struct{ path, name string }{
+		{"os", "os"},
+		{"strings", "str"},
+		{"container/vector", "vector"},
+		{"math", "math_1"},
+		{"other/os", "os_1"},
+	}
+	for _, a := range adds {
+		if name := AddImport(f, a.path); name != a.name {
+			t.Errorf("AddImport(%q) = %q, want %q", a.path, name, a.name)
+		}
+	}
+	if p := Imports(f)["math_1"]; p != "math" {
+		t.Errorf("math_1 imports %q", p)
+	}
+
+	if DeleteImport(f, "bytes") || !DeleteImport(f, "container/vector") {
+		t.Errorf("DeleteImport deleted the wrong imports")
+	}
+	if _, ok := Imports(f)["vector"]; ok {
+		t.Errorf("container/vector is still imported")
+	}
+
+	deleted := DeleteUnusedImports(f)
+	if len(deleted) != 4 {
+		t.Errorf("deleted %v, want fmt, strings, math and other/os", deleted)
+	}
+	paths = Imports(f)
+	if len(paths) != 2 || paths["os"] != "os" || paths["_"] != "http" {
+		t.Errorf("left imports %v, want os and http", paths)
+	}
+
+	DeleteImport(f, "os")
+	DeleteImport(f, "http")
+	if len(f.Decls) != 2 {
+		t.Errorf("the empty import declaration was left in place")
+	}
+}
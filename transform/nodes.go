@@ -0,0 +1,122 @@
+// Copyright 2010 David Roundy, roundyd@physics.oregonstate.edu.
+// All rights reserved.
+
+package transform
+
+import (
+	"container/vector"
+	"go/ast"
+	"os"
+	"reflect"
+)
+
+
+// A NodeType tells the traversals of this package how to handle a
+// kind of node that go/ast does not define, such as one introduced by
+// an extension.
+type NodeType struct {
+	// Walk walks each child of node with v by calling Walk, and
+	// replaces the child with the result, as Walk does for the
+	// nodes of go/ast.
+	Walk func(v Visitor, node interface{})
+
+	// Copy returns a deep copy of node, as Copy does for the nodes
+	// of go/ast.  It may call Copy for the children.
+	Copy func(node interface{}) interface{}
+}
+
+
+var customNodes = make(map[reflect.Type]*NodeType)
+
+
+// RegisterNode makes Walk, Apply, Copy, Equal and Query accept the
+// nodes of the same type as example, such as (*MyExpr)(nil), handling
+// them as t says.  The children of such a node are known to Apply,
+// Equal and Query as one list, named Children, in the order in which
+// t.Walk walks them.  RegisterNode panics if the type has already
+// been registered.
+func RegisterNode(example interface{}, t *NodeType) {
+	typ := reflect.Typeof(example)
+	if _, ok := customNodes[typ]; ok {
+		panic("node type registered twice: " + typ.String())
+	}
+	customNodes[typ] = t
+}
+
+
+// nodeType returns how to handle node, which must be of a registered
+// type, or panics with an UnknownNodeError if it is not.
+func nodeType(node interface{}) *NodeType {
+	if t, ok := customNodes[reflect.Typeof(node)]; ok {
+		return t
+	}
+	panic(&UnknownNodeError{node})
+}
+
+
+// An UnknownNodeError reports a node of a type that is neither
+// defined by go/ast nor registered with RegisterNode.  The traversals
+// of this package panic with one when they meet such a node, and
+// TryWalk and WalkErrors return it.
+type UnknownNodeError struct {
+	Node interface{}
+}
+
+
+func (e *UnknownNodeError) String() string {
+	return "transform: unexpected node type " + reflect.Typeof(e.Node).String()
+}
+
+
+// CatchUnknownNode recovers from a panic with an UnknownNodeError and
+// sets *err to it, leaving any other panic alone.  It must be called
+// by defer, as in
+//
+//	defer transform.CatchUnknownNode(&err)
+//
+func CatchUnknownNode(err *os.Error) {
+	if x := recover(); x != nil {
+		e, ok := x.(*UnknownNodeError)
+		if !ok {
+			panic(x)
+		}
+		*err = e
+	}
+}
+
+
+// TryWalk walks node as Walk does, but returns an UnknownNodeError
+// rather than panicking if it meets a node of an unknown type, in
+// which case the tree may have been partly transformed.
+func TryWalk(v Visitor, node interface{}) (modifiednode interface{}, err os.Error) {
+	defer CatchUnknownNode(&err)
+	return Walk(v, node), nil
+}
+
+
+// A childVisitor calls f with each child of a node walked by a
+// NodeType, and puts the result in place of the child.  Lists of
+// children are descended into, so that f sees their elements.
+type childVisitor func(child interface{}) interface{}
+
+
+func (f childVisitor) Visit(node interface{}) interface{} {
+	switch node.(type) {
+	case []*ast.Ident, []ast.Expr, []ast.Stmt, []ast.Decl:
+		return nil
+	}
+	return f(node)
+}
+
+
+// children returns the children of node, a node of a registered type,
+// in the order in which its NodeType walks them.
+func children(node interface{}) []interface{} {
+	var list vector.Vector
+	visit := func(child interface{}) interface{} {
+		list.Push(child)
+		return child
+	}
+	nodeType(node).Walk(childVisitor(visit), node)
+	return list
+}
@@ -0,0 +1,103 @@
+// Copyright 2010 David Roundy, roundyd@physics.oregonstate.edu.
+// All rights reserved.
+
+package transform
+
+import (
+	"go/ast"
+	"go/token"
+	"testing"
+)
+
+
+// A pairNode is a node of a type that go/ast does not define.
+type pairNode struct {
+	token.Position
+	X, Y ast.Expr
+}
+
+
+func walkPair(v Visitor, node interface{}) {
+	p := node.(*pairNode)
+	p.X = Walk(v, p.X).(ast.Expr)
+	p.Y = Walk(v, p.Y).(ast.Expr)
+}
+
+
+func copyPair(node interface{}) interface{} {
+	p := *node.(*pairNode)
+	p.X = Copy(p.X).(ast.Expr)
+	p.Y = Copy(p.Y).(ast.Expr)
+	return &p
+}
+
+
+func init() {
+	RegisterNode((*pairNode)(nil), &NodeType{walkPair, copyPair})
+}
+
+
+// A renamer renames identifiers.
+type renamer map[string]string
+
+
+func (r renamer) Visit(node interface{}) interface{} {
+	if id, ok := node.(*ast.Ident); ok && r[id.Name] != "" {
+		return ast.NewIdent(r[id.Name])
+	}
+	return nil
+}
+
+
+func TestRegisterNode(t *testing.T) {
+	sum := &ast.BinaryExpr{X: ast.NewIdent("a"), Op: token.ADD, Y: ast.NewIdent("b")}
+	p := &pairNode{X: ast.NewIdent("a"), Y: sum}
+	c := Copy(p).(*pairNode)
+	if !Equal(p, c, 0) {
+		t.Errorf("the copy differs from the original")
+	}
+
+	Walk(renamer{"a": "z"}, c)
+	if p.X.(*ast.Ident).Name != "a" || sum.X.(*ast.Ident).Name != "a" {
+		t.Errorf("walking the copy changed the original")
+	}
+	if c.X.(*ast.Ident).Name != "z" || c.Y.(*ast.BinaryExpr).X.(*ast.Ident).Name != "z" {
+		t.Errorf("Walk did not rename the children of the node")
+	}
+	if Equal(p, c, 0) {
+		t.Errorf("Equal ignored the children of the node")
+	}
+
+	found := Query(c, isIdent)
+	if len(found) != 3 || found[0].String() != ".Children[0]: *ast.Ident z" {
+		t.Errorf("Query found %v", found)
+	}
+
+	Apply(c, func(cur *Cursor) bool {
+		if id, ok := cur.Node().(*ast.Ident); ok && id.Name == "z" {
+			cur.Replace(ast.NewIdent("y"))
+		}
+		return true
+	}, nil)
+	if c.X.(*ast.Ident).Name != "y" || c.Y.(*ast.BinaryExpr).X.(*ast.Ident).Name != "y" {
+		t.Errorf("Apply did not rename the children of the node")
+	}
+}
+
+
+// A badNode is of a type that is not registered.
+type badNode struct{}
+
+
+func TestUnknownNode(t *testing.T) {
+	out, err := TryWalk(renamer{}, &badNode{})
+	if out != nil || err == nil {
+		t.Fatalf("TryWalk returned %v, %v", out, err)
+	}
+	if _, ok := err.(*UnknownNodeError); !ok || err.String() != "transform: unexpected node type *transform.badNode" {
+		t.Errorf("TryWalk returned the error %q", err)
+	}
+	if _, err := TryWalk(renamer{}, ast.NewIdent("a")); err != nil {
+		t.Errorf("TryWalk returned %q for a known node", err)
+	}
+}
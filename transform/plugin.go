@@ -0,0 +1,64 @@
+// Copyright 2010 David Roundy, roundyd@physics.oregonstate.edu.
+// All rights reserved.
+
+package transform
+
+import (
+	"container/vector"
+)
+
+
+// A Plugin is a transform defined outside of go-crazy, which a package
+// makes available by calling RegisterPlugin from its init function.
+// A go-crazy binary built with the package includes the plugin among
+// its passes.
+type Plugin struct {
+	Name        string
+	Description string
+	Extensions  []string       // the syntax extensions that the transform relies on
+	After       []string       // the passes that must run before this one
+	New         func() Visitor // returns a visitor to walk a package with
+}
+
+
+// Extensions describes each syntax extension that go-crazy parses,
+// by name.
+var Extensions = map[string]string{
+	"dotted-operators": "operators such as .+ and *., which call methods",
+	"operator-methods": "methods named by operators, such as func (a T) .+ (b T) T",
+}
+
+
+var plugins = make(map[string]*Plugin)
+var pluginOrder vector.Vector // the plugins, in the order registered
+
+
+// RegisterPlugin makes p available.  It panics if a plugin of the
+// same name has already been registered, or if p relies on an
+// extension that go-crazy does not parse.
+func RegisterPlugin(p *Plugin) {
+	if _, ok := plugins[p.Name]; ok {
+		panic("plugin registered twice: " + p.Name)
+	}
+	for _, x := range p.Extensions {
+		if _, ok := Extensions[x]; !ok {
+			panic("plugin " + p.Name + " relies on an unknown extension: " + x)
+		}
+	}
+	plugins[p.Name] = p
+	pluginOrder.Push(p)
+}
+
+
+// Plugins returns the plugins registered, in the order registered.
+func Plugins() []*Plugin {
+	list := make([]*Plugin, pluginOrder.Len())
+	for i, p := range pluginOrder {
+		list[i] = p.(*Plugin)
+	}
+	return list
+}
+
+
+// LookupPlugin returns the plugin registered under name, or nil.
+func LookupPlugin(name string) *Plugin { return plugins[name] }
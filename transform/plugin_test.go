@@ -0,0 +1,50 @@
+// Copyright 2010 David Roundy, roundyd@physics.oregonstate.edu.
+// All rights reserved.
+
+package transform
+
+import (
+	"testing"
+)
+
+
+type nopVisitor struct{}
+
+
+func (v nopVisitor) Visit(node interface{}) interface{} { return nil }
+
+
+func newNop() Visitor { return nopVisitor{} }
+
+
+// registerPanics returns true if registering p panics.
+func registerPanics(p *Plugin) (panicked bool) {
+	defer func() { panicked = recover() != nil }()
+	RegisterPlugin(p)
+	return
+}
+
+
+func TestRegisterPlugin(t *testing.T) {
+	before := len(Plugins())
+	a := &Plugin{"test-a", "does nothing", nil, nil, newNop}
+	b := &Plugin{"test-b", "does nothing after test-a", []string{"dotted-operators"}, []string{"test-a"}, newNop}
+	RegisterPlugin(a)
+	RegisterPlugin(b)
+	list := Plugins()
+	if len(list) != before+2 || list[before] != a || list[before+1] != b {
+		t.Errorf("Plugins() = %v, want test-a and test-b last", list)
+	}
+	if LookupPlugin("test-b") != b || LookupPlugin("test-c") != nil {
+		t.Errorf("LookupPlugin found the wrong plugins")
+	}
+	if !registerPanics(&Plugin{"test-a", "again", nil, nil, newNop}) {
+		t.Errorf("a plugin was registered twice")
+	}
+	if !registerPanics(&Plugin{"test-c", "bad", []string{"goto-less"}, nil, newNop}) {
+		t.Errorf("a plugin relying on an unknown extension was registered")
+	}
+	if LookupPlugin("test-c") != nil {
+		t.Errorf("a plugin that failed to register was registered")
+	}
+}
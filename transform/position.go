@@ -0,0 +1,206 @@
+// Copyright 2010 David Roundy, roundyd@physics.oregonstate.edu.
+// All rights reserved.
+
+package transform
+
+import (
+	"container/vector"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"reflect"
+)
+
+
+// A Span is the extent of the source that a node was parsed from.
+type Span struct {
+	Start, End token.Position // the first and last positions within it
+}
+
+
+func (s Span) IsValid() bool { return s.Start.IsValid() }
+
+
+func (s Span) String() string {
+	if !s.IsValid() {
+		return "-"
+	}
+	if s.End.Filename != s.Start.Filename || s.End.Offset == s.Start.Offset {
+		return s.Start.String()
+	}
+	return fmt.Sprintf("%s-%d:%d", s.Start, s.End.Line, s.End.Column)
+}
+
+
+// include extends s to include p, if p is valid.
+func (s *Span) include(p token.Position) {
+	if !p.IsValid() {
+		return
+	}
+	if !s.Start.IsValid() || p.Offset < s.Start.Offset {
+		s.Start = p
+	}
+	if !s.End.IsValid() || p.Offset > s.End.Offset {
+		s.End = p
+	}
+}
+
+
+// SpanOf returns the span of the valid positions within node, which
+// may be of any type accepted by Apply.  Comments are not included.
+func SpanOf(node interface{}) Span { return walkSpans(node, nil, nil) }
+
+
+// An Origins map records the span of source from which each generated
+// or moved node came, so that positions within transformed code may
+// be traced back to the source.  A nil Origins records nothing.
+type Origins map[interface{}]Span
+
+
+// Origin returns the span recorded for node.
+func (o Origins) Origin(node interface{}) (s Span, ok bool) {
+	s, ok = o[node]
+	return
+}
+
+
+// record records s as the origin of node, unless node already has an
+// origin, which is then the earlier and truer one.
+func (o Origins) record(node interface{}, s Span) {
+	if o == nil || !s.IsValid() {
+		return
+	}
+	if _, ok := o[node]; !ok {
+		o[node] = s
+	}
+}
+
+
+// Synthesize gives each missing position within node the start of
+// the span of origin, the construct it was generated from, and
+// records that span in origins as the origin of each node given a
+// position.  Positions already present are left alone, as are missing
+// positions whose absence is meaningful, such as that of the Ellipsis
+// of a CallExpr.  Transforms should call it on the nodes they build.
+func Synthesize(node, origin interface{}, origins Origins) {
+	span := SpanOf(origin)
+	fix := func(n interface{}, f positionField) {
+		if !f.optional && !f.get().IsValid() {
+			f.set(span.Start)
+			origins.record(n, span)
+		}
+	}
+	walkSpans(node, fix, nil)
+}
+
+
+// Relocate moves node to pos, giving every node within it that
+// position, and records in origins the span each of them had before.
+// Transforms should call it on code they move away from where it was
+// parsed, such as an inlined function body.  Positions whose absence
+// is meaningful are neither given nor taken away.
+func Relocate(node interface{}, pos token.Position, origins Origins) {
+	fix := func(n interface{}, f positionField) {
+		if !f.optional || (f.get().IsValid() && pos.IsValid()) {
+			f.set(pos)
+		}
+	}
+	done := func(n interface{}, s Span) { origins.record(n, s) }
+	walkSpans(node, fix, done)
+}
+
+
+// walkSpans traverses node, calling fix, if it is not nil, with each
+// position field of each node other than a comment after adding it to
+// the node's span, and done, if it is not nil, with each node and its
+// span after its children.  It returns the span of node.
+func walkSpans(node interface{}, fix func(n interface{}, f positionField), done func(n interface{}, s Span)) Span {
+	var spans vector.Vector // of *Span, innermost last
+	var all Span
+	pre := func(c *Cursor) bool {
+		switch c.Node().(type) {
+		case *ast.Comment, *ast.CommentGroup:
+			return false
+		}
+		s := new(Span)
+		for _, f := range positionFields(c.Node()) {
+			s.include(f.get())
+			if fix != nil {
+				fix(c.Node(), f)
+			}
+		}
+		spans.Push(s)
+		return true
+	}
+	post := func(c *Cursor) bool {
+		s := spans.Pop().(*Span)
+		if done != nil {
+			done(c.Node(), *s)
+		}
+		outer := &all
+		if spans.Len() > 0 {
+			outer = spans.Last().(*Span)
+		}
+		outer.include(s.Start)
+		outer.include(s.End)
+		return true
+	}
+	Apply(node, pre, post)
+	return all
+}
+
+
+var positionType = reflect.Typeof(token.Position{})
+
+
+// A positionField is a field of a node holding a position.  The
+// absence of an optional position is meaningful: the parentheses of
+// a GenDecl are printed only if they have positions, for instance.
+type positionField struct {
+	value    reflect.Value
+	optional bool
+}
+
+
+func (f positionField) get() token.Position { return f.value.Interface().(token.Position) }
+
+
+func (f positionField) set(p token.Position) { f.value.SetValue(reflect.NewValue(p)) }
+
+
+// positionFields returns the position fields of node.
+func positionFields(node interface{}) []positionField {
+	p, ok := reflect.NewValue(node).(*reflect.PtrValue)
+	if !ok || p.IsNil() {
+		return nil
+	}
+	s, ok := p.Elem().(*reflect.StructValue)
+	if !ok {
+		return nil
+	}
+	t := s.Type().(*reflect.StructType)
+	var fields vector.Vector
+	for i := 0; i < s.NumField(); i++ {
+		if f := s.Field(i); f.Type() == positionType {
+			fields.Push(positionField{f, optionalPosition(node, t.Field(i).Name)})
+		}
+	}
+	out := make([]positionField, fields.Len())
+	for i, f := range fields {
+		out[i] = f.(positionField)
+	}
+	return out
+}
+
+
+// optionalPosition returns true if the position held in the field
+// name of node may meaningfully be absent.
+func optionalPosition(node interface{}, name string) bool {
+	switch node.(type) {
+	case *ast.CallExpr:
+		return name == "Ellipsis"
+	case *ast.GenDecl:
+		return name == "Lparen" || name == "Rparen"
+	}
+	return false
+}
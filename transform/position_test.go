@@ -0,0 +1,101 @@
+// Copyright 2010 David Roundy, roundyd@physics.oregonstate.edu.
+// All rights reserved.
+
+package transform
+
+import (
+	"go/ast"
+	"go/token"
+	"testing"
+	"github.com/droundy/go-crazy/parser"
+)
+
+
+const positionSrc = `package p
+
+func f(a, b int) int {
+	return a +
+		b
+}
+
+func g(xs ...int) {
+	g(xs...)
+}
+`
+
+
+func TestSynthesize(t *testing.T) {
+	f, err := parser.ParseFile("p.go", positionSrc, 0)
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+	ret := f.Decls[0].(*ast.FuncDecl).Body.List[0].(*ast.ReturnStmt)
+	span := SpanOf(ret)
+	if span.String() != "p.go:4:2-5:3" {
+		t.Errorf("SpanOf(return) = %s, want p.go:4:2-5:3", span)
+	}
+
+	var nopos token.Position
+	x := ret.Results[0].(*ast.BinaryExpr).X
+	call := &ast.CallExpr{ast.NewIdent("h"), nopos, []ast.Expr{x}, nopos, nopos}
+	origins := make(Origins)
+	Synthesize(call, ret, origins)
+	for _, p := range []token.Position{call.Fun.Pos(), call.Lparen, call.Rparen} {
+		if p.String() != "p.go:4:2" {
+			t.Errorf("Synthesize gave %s, want p.go:4:2", p)
+		}
+	}
+	if call.Ellipsis.IsValid() {
+		t.Errorf("Synthesize gave the call an ellipsis")
+	}
+	if x.Pos().Line != 4 {
+		t.Errorf("Synthesize moved %s", x.Pos())
+	}
+	if s, ok := origins.Origin(call.Fun); !ok || s.String() != span.String() {
+		t.Errorf("origin of h = %s, %v, want %s", s, ok, span)
+	}
+	if _, ok := origins.Origin(x); ok {
+		t.Errorf("a node that had a position was given an origin")
+	}
+}
+
+
+func TestRelocate(t *testing.T) {
+	f, err := parser.ParseFile("p.go", positionSrc, 0)
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+	ret := f.Decls[0].(*ast.FuncDecl).Body.List[0].(*ast.ReturnStmt)
+	sum := ret.Results[0].(*ast.BinaryExpr)
+	y := sum.Y
+	call := f.Decls[1].(*ast.FuncDecl).Body.List[0].(*ast.ExprStmt).X.(*ast.CallExpr)
+	pos := call.Pos()
+	want := pos.String()
+
+	origins := make(Origins)
+	Relocate(ret, pos, origins)
+	Relocate(call, pos, origins)
+	for _, p := range []token.Position{ret.Pos(), sum.OpPos, y.Pos(), call.Ellipsis} {
+		if p.String() != want {
+			t.Errorf("Relocate left %s, want %s", p, want)
+		}
+	}
+	if s, ok := origins.Origin(y); !ok || s.String() != "p.go:5:3" {
+		t.Errorf("origin of b = %s, %v, want p.go:5:3", s, ok)
+	}
+	if s, ok := origins.Origin(sum); !ok || s.String() != "p.go:4:9-5:3" {
+		t.Errorf("origin of a + b = %s, %v, want p.go:4:9-5:3", s, ok)
+	}
+	// the first origin recorded is kept
+	Relocate(ret, token.Position{}, origins)
+	if s, _ := origins.Origin(y); s.String() != "p.go:5:3" {
+		t.Errorf("origin of b = %s after a second move", s)
+	}
+	if ret.Pos().IsValid() {
+		t.Errorf("moving to no position left %s", ret.Pos())
+	}
+	Relocate(call, token.Position{}, nil)
+	if !call.Ellipsis.IsValid() {
+		t.Errorf("moving to no position took away the ellipsis")
+	}
+}
@@ -0,0 +1,293 @@
+// Copyright 2010 David Roundy, roundyd@physics.oregonstate.edu.
+// All rights reserved.
+
+package transform
+
+import (
+	"container/vector"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"reflect"
+	"strconv"
+	"strings"
+	"github.com/droundy/go-crazy/parser"
+)
+
+
+// A Match is a node found by a query, together with where it was
+// found.
+type Match struct {
+	Node   interface{}
+	Path   string // the fields and indices leading to Node from the root, as in a Difference
+	Parent *Match // the match of the node holding Node, or nil if Node is the root
+}
+
+
+func (m *Match) String() string {
+	path := m.Path
+	if path == "" {
+		path = "."
+	}
+	return path + ": " + describe(m.Node)
+}
+
+
+// Field returns the match of the node at path below m.Node, where
+// path is relative to m.Path, such as ".Fun.X" or ".Args[0]".  It
+// returns nil if there is no node at path.
+func (m *Match) Field(path string) *Match {
+	for path != "" {
+		if path[0] != '.' {
+			return nil
+		}
+		end := strings.Index(path[1:], ".") + 1
+		if end == 0 {
+			end = len(path)
+		}
+		name, index := path[1:end], -1
+		if bracket := strings.Index(name, "["); bracket >= 0 && name[len(name)-1] == ']' {
+			i, err := strconv.Atoi(name[bracket+1 : len(name)-1])
+			if err != nil {
+				return nil
+			}
+			name, index = name[0:bracket], i
+		}
+		var child interface{}
+		for _, f := range fields(m.Node) {
+			if f.name == name {
+				child = f.value
+			}
+		}
+		if l, ok := child.([]interface{}); ok && index >= 0 && index < len(l) {
+			child = l[index]
+		} else if ok || index >= 0 {
+			return nil
+		}
+		if child == nil || isScalar(child) {
+			return nil
+		}
+		m = &Match{child, m.Path + path[0:end], m}
+		path = path[end:]
+	}
+	return m
+}
+
+
+func isScalar(x interface{}) bool {
+	switch x.(type) {
+	case token.Token, ast.ChanDir, string, bool:
+		return true
+	}
+	return false
+}
+
+
+// each calls f with m and then with the match of each node below
+// m.Node, other than comments, in the order in which Walk visits
+// them, until f returns false.  It returns false if f did.
+func (m *Match) each(f func(m *Match) bool) bool {
+	if !f(m) {
+		return false
+	}
+	for _, fl := range fields(m.Node) {
+		if !fl.isComment() && !eachValue(m, m.Path+"."+fl.name, fl.value, f) {
+			return false
+		}
+	}
+	return true
+}
+
+
+func eachValue(parent *Match, path string, x interface{}, f func(m *Match) bool) bool {
+	if l, ok := x.([]interface{}); ok {
+		for i, y := range l {
+			if !eachValue(parent, fmt.Sprintf("%s[%d]", path, i), y, f) {
+				return false
+			}
+		}
+		return true
+	}
+	if x == nil || isScalar(x) {
+		return true
+	}
+	return (&Match{x, path, parent}).each(f)
+}
+
+
+// A Predicate decides whether a query matches a node.
+type Predicate func(m *Match) bool
+
+
+// Query returns the matches of p among root and the nodes within it,
+// other than comments, in the order in which Walk visits them.  root
+// may be any node, including a Package.
+func Query(root interface{}, p Predicate) []*Match {
+	var found vector.Vector
+	(&Match{root, "", nil}).each(func(m *Match) bool {
+		if p(m) {
+			found.Push(m)
+		}
+		return true
+	})
+	list := make([]*Match, found.Len())
+	for i, m := range found {
+		list[i] = m.(*Match)
+	}
+	return list
+}
+
+
+// Type matches the nodes of the same type as example, such as
+// (*ast.CallExpr)(nil).
+func Type(example interface{}) Predicate {
+	t := reflect.Typeof(example)
+	return func(m *Match) bool { return reflect.Typeof(m.Node) == t }
+}
+
+
+// And matches the nodes matched by all of ps.
+func And(ps ...Predicate) Predicate {
+	return func(m *Match) bool {
+		for _, p := range ps {
+			if !p(m) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+
+// Or matches the nodes matched by any of ps.
+func Or(ps ...Predicate) Predicate {
+	return func(m *Match) bool {
+		for _, p := range ps {
+			if p(m) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+
+// Not matches the nodes not matched by p.
+func Not(p Predicate) Predicate {
+	return func(m *Match) bool { return !p(m) }
+}
+
+
+// Inside matches the nodes with an ancestor matched by p.
+func Inside(p Predicate) Predicate {
+	return func(m *Match) bool {
+		for a := m.Parent; a != nil; a = a.Parent {
+			if p(a) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+
+// Contains matches the nodes with a descendant matched by p.
+func Contains(p Predicate) Predicate {
+	return func(m *Match) bool {
+		found := false
+		m.each(func(d *Match) bool {
+			found = d != m && p(d)
+			return !found
+		})
+		return found
+	}
+}
+
+
+// Field matches the nodes whose child at path, as understood by
+// Match.Field, is matched by p.
+func Field(path string, p Predicate) Predicate {
+	return func(m *Match) bool {
+		c := m.Field(path)
+		return c != nil && p(c)
+	}
+}
+
+
+// Within matches the nodes lying entirely between start and end,
+// inclusive.
+func Within(start, end token.Position) Predicate {
+	return func(m *Match) bool {
+		s := SpanOf(m.Node)
+		return s.IsValid() && s.Start.Filename == start.Filename && s.End.Filename == end.Filename &&
+			s.Start.Offset >= start.Offset && s.End.Offset <= end.Offset
+	}
+}
+
+
+// operatorMethod returns the name of the method called by the dotted
+// operator op, such as ".*" or "*.", or "" if op is not one.
+func operatorMethod(op string) string {
+	if op == "*." {
+		return "_mul_dot"
+	}
+	ops := []token.Token{token.ADD, token.ADD_ASSIGN, token.SUB, token.SUB_ASSIGN,
+		token.MUL, token.MUL_ASSIGN, token.QUO, token.QUO_ASSIGN}
+	for _, tok := range ops {
+		if op == "."+tok.String() {
+			return parser.MungeOperator(tok)
+		}
+	}
+	return ""
+}
+
+
+// Operator matches the calls made by the dotted operator op, such as
+// ".*" or "*.".
+func Operator(op string) Predicate {
+	name := operatorMethod(op)
+	return func(m *Match) bool {
+		if call, ok := m.Node.(*ast.CallExpr); ok && name != "" {
+			sel, ok := call.Fun.(*ast.SelectorExpr)
+			return ok && sel.Sel.Name == name && len(call.Args) == 1
+		}
+		return false
+	}
+}
+
+
+// operands returns the paths of the left and right operands of the
+// dotted operator call m.Node, or "" if it is not one.
+func operands(m *Match) (left, right string) {
+	if call, ok := m.Node.(*ast.CallExpr); ok && len(call.Args) == 1 {
+		if sel, ok := call.Fun.(*ast.SelectorExpr); ok {
+			switch {
+			case sel.Sel.Name == "_mul_dot":
+				return ".Args[0]", ".Fun.X"
+			case strings.HasPrefix(sel.Sel.Name, "_dot_"):
+				return ".Fun.X", ".Args[0]"
+			}
+		}
+	}
+	return "", ""
+}
+
+
+// LeftOperand matches the dotted operator calls whose left operand,
+// as written, is matched by p.
+func LeftOperand(p Predicate) Predicate {
+	return func(m *Match) bool {
+		left, _ := operands(m)
+		return left != "" && Field(left, p)(m)
+	}
+}
+
+
+// RightOperand matches the dotted operator calls whose right operand,
+// as written, is matched by p.
+func RightOperand(p Predicate) Predicate {
+	return func(m *Match) bool {
+		_, right := operands(m)
+		return right != "" && Field(right, p)(m)
+	}
+}
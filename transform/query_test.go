@@ -0,0 +1,87 @@
+// Copyright 2010 David Roundy, roundyd@physics.oregonstate.edu.
+// All rights reserved.
+
+package transform
+
+import (
+	"go/ast"
+	"testing"
+	"github.com/droundy/go-crazy/parser"
+)
+
+
+const querySrc = `package p
+
+type V []float64
+
+func f(a, b V) V {
+	c := V{1, 2} .* a
+	d := a .* V{3}
+	e := 2 *. a
+	return c .+ d .+ e
+}
+`
+
+
+var (
+	isCall    = Type((*ast.CallExpr)(nil))
+	isIdent   = Type((*ast.Ident)(nil))
+	isLiteral = Type((*ast.CompositeLit)(nil))
+)
+
+
+func TestQuery(t *testing.T) {
+	file, err := parser.ParseFile("", querySrc, 0)
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+	first := file.Decls[1].(*ast.FuncDecl).Body.List[0]
+	span := SpanOf(first)
+	tests := []struct {
+		p     Predicate
+		count int
+		first string // the first match
+	}{
+		{And(isCall, Operator(".*"), LeftOperand(isLiteral)), 1, ".Decls[1].Body.List[0].Rhs[0]: *ast.CallExpr V{1, 2}._dot_mul(a)"},
+		{And(Operator(".*"), RightOperand(isLiteral)), 1, ".Decls[1].Body.List[1].Rhs[0]: *ast.CallExpr a._dot_mul(V{3})"},
+		{Operator(".*"), 2, ".Decls[1].Body.List[0].Rhs[0]: *ast.CallExpr V{1, 2}._dot_mul(a)"},
+		{And(Operator("*."), LeftOperand(Type((*ast.BasicLit)(nil)))), 1, ".Decls[1].Body.List[2].Rhs[0]: *ast.CallExpr a._mul_dot(2)"},
+		{And(isIdent, Inside(Operator("*."))), 2, ".Decls[1].Body.List[2].Rhs[0].Fun.X: *ast.Ident a"},
+		{And(Type((*ast.ReturnStmt)(nil)), Contains(Operator(".+"))), 1, ".Decls[1].Body.List[3]: *ast.ReturnStmt return c._dot_add(d)._dot_add(e)"},
+		{And(isIdent, Within(span.Start, span.End)), 4, ".Decls[1].Body.List[0].Lhs[0]: *ast.Ident c"},
+		{Field(".Fun.Sel", isIdent), 5, ".Decls[1].Body.List[0].Rhs[0]: *ast.CallExpr V{1, 2}._dot_mul(a)"},
+		{Or(Operator("./"), Operator(".%")), 0, ""},
+		{And(isCall, Not(Operator(".+"))), 3, ".Decls[1].Body.List[0].Rhs[0]: *ast.CallExpr V{1, 2}._dot_mul(a)"},
+	}
+	for i, test := range tests {
+		found := Query(file, test.p)
+		if len(found) != test.count {
+			t.Errorf("%d: found %d matches, want %d", i, len(found), test.count)
+			continue
+		}
+		if test.first != "" && found[0].String() != test.first {
+			t.Errorf("%d: first match is %s, want %s", i, found[0], test.first)
+		}
+	}
+}
+
+
+func TestMatchField(t *testing.T) {
+	file, err := parser.ParseFile("", querySrc, 0)
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+	root := &Match{file, "", nil}
+	m := root.Field(".Decls[1].Body.List[2].Rhs[0].Args[0]")
+	if m == nil || m.String() != ".Decls[1].Body.List[2].Rhs[0].Args[0]: *ast.BasicLit 2" {
+		t.Fatalf("Field found %v", m)
+	}
+	if _, ok := m.Parent.Node.(*ast.CallExpr); !ok {
+		t.Errorf("the parent of the argument is %T", m.Parent.Node)
+	}
+	for _, path := range []string{".Decls[2]", ".Name.Name", ".Decls", ".Name[0]", "Decls[0]"} {
+		if m := root.Field(path); m != nil {
+			t.Errorf("Field(%q) found %v", path, m)
+		}
+	}
+}
@@ -0,0 +1,424 @@
+// Copyright 2010 David Roundy, roundyd@physics.oregonstate.edu.
+// All rights reserved.
+
+package transform
+
+import (
+	"container/vector"
+	"go/ast"
+	"go/token"
+)
+
+
+// A Resolution records the declaration that each identifier within
+// an AST refers to, as found by Resolve.  Declarations are identified
+// by the identifiers that declare them.
+type Resolution struct {
+	decls vector.Vector // of *ast.Ident, in the order declared
+	refs  map[*ast.Ident]*ast.Ident
+}
+
+
+// Resolve resolves the identifiers within node, which may be of any
+// type accepted by Walk, following Go's scoping rules.  The top-level
+// names of a File or Package are in scope throughout it; the files of
+// a package share their top-level scope.
+//
+// Only names declared within node are resolved.  Predeclared names,
+// package names, the top-level names of other files when node is a
+// File, and the locals of an enclosing function when node is a
+// statement all remain unresolved.  So do labels, the names of fields
+// and methods, and the keys of composite literals whose type is not
+// known to be a map or array type, none of which are scoped like other
+// names.
+func Resolve(node interface{}) *Resolution {
+	r := &Resolution{refs: make(map[*ast.Ident]*ast.Ident)}
+	Walk(&resolver{r: r, types: make(map[*ast.Ident]*ast.TypeSpec)}, node)
+	return r
+}
+
+
+// Decl returns the identifier that declares what id refers to, which
+// is id itself if id is the declaring identifier, or nil if id was not
+// resolved.  A nil Resolution resolves nothing.
+func (r *Resolution) Decl(id *ast.Ident) *ast.Ident {
+	if r == nil {
+		return nil
+	}
+	return r.refs[id]
+}
+
+
+// Decls returns the declaring identifiers found by Resolve, in the
+// order in which they were declared.
+func (r *Resolution) Decls() []*ast.Ident {
+	decls := make([]*ast.Ident, r.decls.Len())
+	for i, x := range r.decls {
+		decls[i] = x.(*ast.Ident)
+	}
+	return decls
+}
+
+
+type resolver struct {
+	r      *Resolution
+	scopes vector.Vector                // of map[string]*ast.Ident, innermost last
+	types  map[*ast.Ident]*ast.TypeSpec // the type declarations, by name
+}
+
+
+func (v *resolver) open() { v.scopes.Push(make(map[string]*ast.Ident)) }
+
+
+func (v *resolver) close() { v.scopes.Pop() }
+
+
+// declare declares id in the innermost scope.
+func (v *resolver) declare(id *ast.Ident) {
+	if id.Name == "_" {
+		return
+	}
+	if v.scopes.Len() == 0 {
+		v.open()
+	}
+	v.scopes.Last().(map[string]*ast.Ident)[id.Name] = id
+	v.r.refs[id] = id
+	v.r.decls.Push(id)
+}
+
+
+// redeclare declares id in the innermost scope unless it is already
+// declared there, as the left side of := does.
+func (v *resolver) redeclare(id *ast.Ident) {
+	if v.scopes.Len() > 0 {
+		if d, ok := v.scopes.Last().(map[string]*ast.Ident)[id.Name]; ok {
+			v.r.refs[id] = d
+			return
+		}
+	}
+	v.declare(id)
+}
+
+
+// use resolves id in the innermost scope declaring its name.
+func (v *resolver) use(id *ast.Ident) {
+	for i := v.scopes.Len() - 1; i >= 0; i-- {
+		if d, ok := v.scopes.At(i).(map[string]*ast.Ident)[id.Name]; ok {
+			v.r.refs[id] = d
+			return
+		}
+	}
+}
+
+
+// declareTop declares the top-level names of f, other than those of
+// methods and imported packages.
+func (v *resolver) declareTop(f *ast.File) {
+	for _, d := range f.Decls {
+		switch d := d.(type) {
+		case *ast.FuncDecl:
+			if d.Recv == nil {
+				v.declare(d.Name)
+			}
+		case *ast.GenDecl:
+			for _, s := range d.Specs {
+				switch s := s.(type) {
+				case *ast.TypeSpec:
+					v.declare(s.Name)
+					v.types[s.Name] = s
+				case *ast.ValueSpec:
+					for _, id := range s.Names {
+						v.declare(id)
+					}
+				}
+			}
+		}
+	}
+}
+
+
+// topDecl resolves the identifiers within a top-level declaration,
+// whose names have already been declared.
+func (v *resolver) topDecl(d ast.Decl) {
+	g, ok := d.(*ast.GenDecl)
+	if !ok {
+		Walk(v, d)
+		return
+	}
+	for _, s := range g.Specs {
+		switch s := s.(type) {
+		case *ast.TypeSpec:
+			v.use(s.Name)
+			s.Type = walkExpr(v, s.Type)
+		case *ast.ValueSpec:
+			for _, id := range s.Names {
+				v.use(id)
+			}
+			s.Type = walkExpr(v, s.Type)
+			s.Values = Walk(v, s.Values).([]ast.Expr)
+		}
+	}
+}
+
+
+func (v *resolver) declareFields(l *ast.FieldList) {
+	if l != nil {
+		for _, f := range l.List {
+			f.Type = walkExpr(v, f.Type)
+			for _, id := range f.Names {
+				v.declare(id)
+			}
+		}
+	}
+}
+
+
+// walkBody walks the statements of a function body in the scope of
+// its parameters and results, which Go counts as the same block.
+func (v *resolver) walkBody(b *ast.BlockStmt) {
+	if b != nil {
+		b.List = Walk(v, b.List).([]ast.Stmt)
+	}
+}
+
+
+// keyed returns true if the keys of a composite literal of type typ
+// are values, as those of maps and arrays are, rather than field
+// names, looking through depth type declarations so far.
+func (v *resolver) keyed(typ ast.Expr, depth int) bool {
+	switch t := typ.(type) {
+	case *ast.MapType, *ast.ArrayType:
+		return true
+	case *ast.ParenExpr:
+		return v.keyed(t.X, depth)
+	case *ast.Ident:
+		if s := v.types[v.r.Decl(t)]; s != nil && depth < 8 {
+			return v.keyed(s.Type, depth+1)
+		}
+	}
+	return false
+}
+
+
+func (v *resolver) walkFieldTypes(l *ast.FieldList) {
+	if l != nil {
+		for _, f := range l.List {
+			f.Type = walkExpr(v, f.Type)
+		}
+	}
+}
+
+
+// Visit handles every node that declares names or opens a scope
+// itself, and leaves the rest to Walk.
+func (v *resolver) Visit(node interface{}) interface{} {
+	switch n := node.(type) {
+	case *ast.Ident:
+		v.use(n)
+		return n
+
+	case *ast.SelectorExpr:
+		n.X = walkExpr(v, n.X)
+		return n
+
+	case *ast.CompositeLit:
+		n.Type = walkExpr(v, n.Type)
+		keyed := v.keyed(n.Type, 0)
+		for i, x := range n.Elts {
+			if kv, ok := x.(*ast.KeyValueExpr); ok && !keyed {
+				// a field name, unless it isn't an identifier
+				if _, ok := kv.Key.(*ast.Ident); !ok {
+					kv.Key = walkExpr(v, kv.Key)
+				}
+				kv.Value = walkExpr(v, kv.Value)
+			} else {
+				n.Elts[i] = walkExpr(v, x)
+			}
+		}
+		return n
+
+	case *ast.StructType:
+		v.walkFieldTypes(n.Fields)
+		return n
+
+	case *ast.InterfaceType:
+		v.walkFieldTypes(n.Methods)
+		return n
+
+	case *ast.FuncType:
+		// a function type, rather than a function's signature
+		v.walkFieldTypes(n.Params)
+		v.walkFieldTypes(n.Results)
+		return n
+
+	case *ast.FuncLit:
+		v.open()
+		v.declareFields(n.Type.Params)
+		v.declareFields(n.Type.Results)
+		v.walkBody(n.Body)
+		v.close()
+		return n
+
+	case *ast.FuncDecl:
+		if n.Recv == nil {
+			v.use(n.Name)
+		}
+		v.open()
+		v.declareFields(n.Recv)
+		v.declareFields(n.Type.Params)
+		v.declareFields(n.Type.Results)
+		v.walkBody(n.Body)
+		v.close()
+		return n
+
+	case *ast.LabeledStmt:
+		n.Stmt = walkStmt(v, n.Stmt)
+		return n
+
+	case *ast.BranchStmt:
+		return n
+
+	case *ast.AssignStmt:
+		n.Rhs = Walk(v, n.Rhs).([]ast.Expr)
+		if n.Tok == token.DEFINE {
+			for _, x := range n.Lhs {
+				if id, ok := x.(*ast.Ident); ok {
+					v.redeclare(id)
+				}
+			}
+		} else {
+			n.Lhs = Walk(v, n.Lhs).([]ast.Expr)
+		}
+		return n
+
+	case *ast.BlockStmt:
+		v.open()
+		n.List = Walk(v, n.List).([]ast.Stmt)
+		v.close()
+		return n
+
+	case *ast.IfStmt:
+		v.open()
+		n.Init = walkStmt(v, n.Init)
+		n.Cond = walkExpr(v, n.Cond)
+		n.Body = walkBlockStmt(v, n.Body)
+		n.Else = walkStmt(v, n.Else)
+		v.close()
+		return n
+
+	case *ast.CaseClause:
+		n.Values = Walk(v, n.Values).([]ast.Expr)
+		v.open()
+		n.Body = Walk(v, n.Body).([]ast.Stmt)
+		v.close()
+		return n
+
+	case *ast.SwitchStmt:
+		v.open()
+		n.Init = walkStmt(v, n.Init)
+		n.Tag = walkExpr(v, n.Tag)
+		n.Body = walkBlockStmt(v, n.Body)
+		v.close()
+		return n
+
+	case *ast.TypeCaseClause:
+		n.Types = Walk(v, n.Types).([]ast.Expr)
+		v.open()
+		n.Body = Walk(v, n.Body).([]ast.Stmt)
+		v.close()
+		return n
+
+	case *ast.TypeSwitchStmt:
+		v.open()
+		n.Init = walkStmt(v, n.Init)
+		n.Assign = walkStmt(v, n.Assign)
+		n.Body = walkBlockStmt(v, n.Body)
+		v.close()
+		return n
+
+	case *ast.CommClause:
+		n.Rhs = walkExpr(v, n.Rhs)
+		v.open()
+		if id, ok := n.Lhs.(*ast.Ident); ok && n.Tok == token.DEFINE {
+			v.declare(id)
+		} else {
+			n.Lhs = walkExpr(v, n.Lhs)
+		}
+		n.Body = Walk(v, n.Body).([]ast.Stmt)
+		v.close()
+		return n
+
+	case *ast.ForStmt:
+		v.open()
+		n.Init = walkStmt(v, n.Init)
+		n.Cond = walkExpr(v, n.Cond)
+		n.Post = walkStmt(v, n.Post)
+		n.Body = walkBlockStmt(v, n.Body)
+		v.close()
+		return n
+
+	case *ast.RangeStmt:
+		n.X = walkExpr(v, n.X)
+		v.open()
+		if n.Tok == token.DEFINE {
+			if id, ok := n.Key.(*ast.Ident); ok {
+				v.declare(id)
+			}
+			if id, ok := n.Value.(*ast.Ident); ok {
+				v.declare(id)
+			}
+		} else {
+			n.Key = walkExpr(v, n.Key)
+			n.Value = walkExpr(v, n.Value)
+		}
+		n.Body = walkBlockStmt(v, n.Body)
+		v.close()
+		return n
+
+	case *ast.ImportSpec:
+		// package names are not resolved
+		return n
+
+	case *ast.ValueSpec:
+		// a local declaration: top-level ones are handled by topDecl
+		n.Type = walkExpr(v, n.Type)
+		n.Values = Walk(v, n.Values).([]ast.Expr)
+		for _, id := range n.Names {
+			v.declare(id)
+		}
+		return n
+
+	case *ast.TypeSpec:
+		// the scope of a type name includes its own definition
+		v.declare(n.Name)
+		v.types[n.Name] = n
+		n.Type = walkExpr(v, n.Type)
+		return n
+
+	case *ast.File:
+		top := v.scopes.Len() == 0
+		if top {
+			v.open()
+			v.declareTop(n)
+		}
+		for _, d := range n.Decls {
+			v.topDecl(d)
+		}
+		if top {
+			v.close()
+		}
+		return n
+
+	case *ast.Package:
+		v.open()
+		for _, f := range n.Files {
+			v.declareTop(f)
+		}
+		for _, f := range n.Files {
+			Walk(v, f)
+		}
+		v.close()
+		return n
+	}
+	return nil
+}
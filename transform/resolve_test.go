@@ -0,0 +1,112 @@
+// Copyright 2010 David Roundy, roundyd@physics.oregonstate.edu.
+// All rights reserved.
+
+package transform
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/printer"
+	"strings"
+	"testing"
+	"github.com/droundy/go-crazy/parser"
+)
+
+
+const resolveSrc = `package p
+
+var x int
+
+func f(x int) int {
+	return x
+}
+
+func g() int {
+	x := 1
+	if x := 2; x > 0 {
+		return x
+	}
+	return x + h()
+}
+
+func h() int {
+	return x
+}
+
+type T struct {
+	x int
+}
+
+func (t T) get() int {
+	return t.x
+}
+
+func k() (n int, err int) {
+	y, err := n, 2
+	return
+}
+
+type M map[string]int
+
+func m(key string) M {
+	return M{key: 1}
+}
+`
+
+
+// resolved holds lines of resolveSrc in which each resolved identifier
+// is suffixed with the line of its declaration.
+var resolved = []string{
+	"var x@3 int",
+	"func f@5(x@5 int) int",
+	"return x@5",
+	"func g@9() int",
+	"x@10 := 1",
+	"if x@11 := 2; x@11 > 0",
+	"return x@11",
+	"return x@10 + h@17()",
+	"return x@3",
+	"type T@21 struct",
+	"func (t@25 T@21) get() int",
+	"return t@25.x",
+	"y@30, err@29 := n@29, 2",
+	"type M@34 map[string]int",
+	"return M@34{key@36: 1}",
+}
+
+
+// A declMarker suffixes every resolved identifier with the line of
+// its declaration.
+type declMarker struct {
+	r *Resolution
+}
+
+func (v declMarker) Visit(node interface{}) interface{} {
+	if id, ok := node.(*ast.Ident); ok {
+		if d := v.r.Decl(id); d != nil {
+			id.Name += fmt.Sprintf("@%d", d.Pos().Line)
+		}
+	}
+	return nil
+}
+
+
+func TestResolve(t *testing.T) {
+	f, err := parser.ParseFile("", resolveSrc, 0)
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+	pkg := &ast.Package{"p", nil, map[string]*ast.File{"p.go": f}}
+	r := Resolve(pkg)
+	Walk(declMarker{r}, pkg)
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, f); err != nil {
+		t.Fatalf("Fprint: %v", err)
+	}
+	for _, s := range resolved {
+		if strings.Index(buf.String(), s) < 0 {
+			t.Errorf("expected %q in resolved file:\n%s", s, buf.String())
+		}
+	}
+}
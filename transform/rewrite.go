@@ -0,0 +1,269 @@
+// Copyright 2010 David Roundy, roundyd@physics.oregonstate.edu.
+// All rights reserved.
+
+package transform
+
+import (
+	"container/vector"
+	"go/ast"
+	"go/token"
+	"os"
+	"reflect"
+	"strings"
+	"github.com/droundy/go-crazy/parser"
+)
+
+
+// A Rule rewrites each match of its pattern into its replacement.
+// Either both are expressions, or both are lists of statements.
+// Within the pattern, an identifier consisting of a single lower-case
+// letter, other than the name selected by a selector, is a wildcard,
+// which matches any expression; every
+// occurrence of a wildcard must match the same expression, which is
+// substituted for that wildcard in the replacement.
+type Rule struct {
+	Pattern     interface{} // an ast.Expr or a []ast.Stmt
+	Replacement interface{} // of the same type as Pattern
+}
+
+
+// ParseRule parses a rule written as "pattern -> replacement", in
+// which pattern and replacement are either both expressions or both
+// lists of statements, such as "a .+ b -> add(a, b)" or
+// "x = x + 1 -> x++".  An empty replacement for a statement pattern
+// deletes the statements matched.
+func ParseRule(rule string) (*Rule, os.Error) {
+	arrow := strings.Index(rule, "->")
+	if arrow < 0 {
+		return nil, os.NewError("rewrite rule must have the form 'pattern -> replacement': " + rule)
+	}
+	lhs, rhs := rule[0:arrow], rule[arrow+2:]
+	if p, err := parser.ParseExpr("pattern", lhs); err == nil {
+		r, err := parser.ParseExpr("replacement", rhs)
+		if err != nil {
+			return nil, err
+		}
+		return &Rule{p, r}, nil
+	}
+	p, err := parser.ParseStmtList("pattern", lhs)
+	if err != nil {
+		return nil, err
+	}
+	if len(p) == 0 {
+		return nil, os.NewError("rewrite rule has an empty pattern: " + rule)
+	}
+	r, err := parser.ParseStmtList("replacement", rhs)
+	if err != nil {
+		return nil, err
+	}
+	return &Rule{p, r}, nil
+}
+
+
+// Rewrite rewrites the matches of rules within node, which may be of
+// any type accepted by Apply, and returns the result.  Children are
+// rewritten before their parents, and each node is rewritten by the
+// first rule that matches it.  Replacements are not rewritten again.
+// A statement pattern matches a run of consecutive statements within
+// a block or clause.  The code taken from a replacement is given the
+// position of the code it replaces, which is recorded in origins.
+func Rewrite(node interface{}, rules []*Rule, origins Origins) interface{} {
+	post := func(c *Cursor) bool {
+		switch n := c.Node().(type) {
+		case *ast.BlockStmt:
+			n.List = rewriteStmts(n.List, rules, origins)
+		case *ast.CaseClause:
+			n.Body = rewriteStmts(n.Body, rules, origins)
+		case *ast.TypeCaseClause:
+			n.Body = rewriteStmts(n.Body, rules, origins)
+		case *ast.CommClause:
+			n.Body = rewriteStmts(n.Body, rules, origins)
+		case ast.Expr:
+			for _, r := range rules {
+				p, ok := r.Pattern.(ast.Expr)
+				if !ok {
+					continue
+				}
+				m := matcher(make(map[string]ast.Expr))
+				if !m.match(p, n, true) {
+					continue
+				}
+				x := m.subst(r.Replacement, n, origins).(ast.Expr)
+				if reflect.Typeof(x) == reflect.Typeof(n) || exprSlot(c) {
+					c.Replace(x)
+					break
+				}
+			}
+		}
+		return true
+	}
+	return Apply(node, nil, post)
+}
+
+
+// rewriteStmts rewrites the runs of statements within list that match
+// the statement rules.
+func rewriteStmts(list []ast.Stmt, rules []*Rule, origins Origins) []ast.Stmt {
+	var out vector.Vector
+	changed := false
+	for i := 0; i < len(list); {
+		matched := false
+		for _, r := range rules {
+			p, ok := r.Pattern.([]ast.Stmt)
+			if !ok || i+len(p) > len(list) {
+				continue
+			}
+			m := matcher(make(map[string]ast.Expr))
+			ok = true
+			for j, s := range p {
+				if !m.match(s, list[i+j], true) {
+					ok = false
+					break
+				}
+			}
+			if ok {
+				for _, s := range r.Replacement.([]ast.Stmt) {
+					out.Push(m.subst(s, list[i], origins))
+				}
+				i += len(p)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			out.Push(list[i])
+			i++
+		}
+		changed = changed || matched
+	}
+	if !changed {
+		return list
+	}
+	stmts := make([]ast.Stmt, out.Len())
+	for i, s := range out {
+		stmts[i] = s.(ast.Stmt)
+	}
+	return stmts
+}
+
+
+// exprSlot returns true if the node at c is held in a field that may
+// hold any expression, rather than only an identifier or some other
+// particular kind of expression.
+func exprSlot(c *Cursor) bool {
+	switch c.Parent().(type) {
+	case *ast.SelectorExpr:
+		return c.Name() != "Sel"
+	case *ast.Field, *ast.TypeSpec:
+		return c.Name() == "Type"
+	case *ast.ValueSpec:
+		return c.Name() != "Names"
+	case *ast.FuncLit, *ast.FuncDecl, *ast.LabeledStmt, *ast.BranchStmt,
+		*ast.GoStmt, *ast.DeferStmt, *ast.ImportSpec, *ast.File:
+		return false
+	}
+	return true
+}
+
+
+func isWildcard(name string) bool {
+	return len(name) == 1 && 'a' <= name[0] && name[0] <= 'z'
+}
+
+
+// A matcher holds the expressions matched by the wildcards of a
+// pattern.
+type matcher map[string]ast.Expr
+
+
+// match returns true if node matches pattern, ignoring positions and
+// comments.  If wild is true, the wildcards of pattern match, and are
+// bound to, any expression.
+func (m matcher) match(pattern, node interface{}, wild bool) bool {
+	if id, ok := pattern.(*ast.Ident); ok && wild && isWildcard(id.Name) {
+		x, ok := node.(ast.Expr)
+		if !ok {
+			return false
+		}
+		if bound, ok := m[id.Name]; ok {
+			return m.match(bound, x, false)
+		}
+		m[id.Name] = x
+		return true
+	}
+	if p, ok := pattern.(*ast.SelectorExpr); ok {
+		// the name selected is never a wildcard
+		n, ok := node.(*ast.SelectorExpr)
+		return ok && m.match(p.X, n.X, wild) && m.match(p.Sel, n.Sel, false)
+	}
+	if pattern == nil || node == nil {
+		return pattern == nil && node == nil
+	}
+	if reflect.Typeof(pattern) != reflect.Typeof(node) {
+		return false
+	}
+	pf, nf := fields(pattern), fields(node)
+	if len(pf) != len(nf) {
+		return false
+	}
+	for i, f := range pf {
+		if f.name != nf[i].name {
+			return false
+		}
+		if !f.isComment() && !m.matchValue(f.value, nf[i].value, wild) {
+			return false
+		}
+	}
+	return true
+}
+
+
+func (m matcher) matchValue(p, n interface{}, wild bool) bool {
+	switch p := p.(type) {
+	case []interface{}:
+		l, ok := n.([]interface{})
+		if !ok || len(l) != len(p) {
+			return false
+		}
+		for i, x := range p {
+			if !m.match(x, l[i], wild) {
+				return false
+			}
+		}
+		return true
+	case token.Token, ast.ChanDir, string, bool:
+		return p == n
+	}
+	return m.match(p, n, wild)
+}
+
+
+// subst returns a copy of replacement with the bound wildcards
+// replaced by copies of the expressions they matched, in which the
+// code taken from replacement has the position of origin.
+func (m matcher) subst(replacement, origin interface{}, origins Origins) interface{} {
+	x := Copy(replacement)
+	span := SpanOf(origin)
+	Relocate(x, span.Start, nil)
+	Apply(x, func(c *Cursor) bool {
+		origins.record(c.Node(), span)
+		return true
+	}, nil)
+	return Walk(m, x)
+}
+
+
+func (m matcher) Visit(node interface{}) interface{} {
+	switch n := node.(type) {
+	case *ast.Ident:
+		if x, ok := m[n.Name]; ok && isWildcard(n.Name) {
+			return Copy(x)
+		}
+		return n
+	case *ast.SelectorExpr:
+		// the selector must remain an identifier
+		n.X = walkExpr(m, n.X)
+		return n
+	}
+	return nil
+}
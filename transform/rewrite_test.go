@@ -0,0 +1,115 @@
+// Copyright 2010 David Roundy, roundyd@physics.oregonstate.edu.
+// All rights reserved.
+
+package transform
+
+import (
+	"bytes"
+	"go/printer"
+	"strings"
+	"testing"
+	"github.com/droundy/go-crazy/parser"
+)
+
+
+const rewriteSrc = `package p
+
+func f(x, y, z Vec) Vec {
+	n := 0
+	n = n + 1
+	n = n + 2
+	m := y.x + y.z
+	return x .+ (y .+ z)
+}
+`
+
+
+var rewriteTests = []struct {
+	rules    []string
+	expected []string
+	absent   []string
+}{
+	{[]string{"a .+ b -> add(a, b)"}, []string{"return add(x, (add(y, z)))"}, []string{"_dot_add"}},
+	{[]string{"x = x + 1 -> x++"}, []string{"n++", "n = n + 2"}, []string{"n + 1"}},
+	{[]string{"n = n + 1; n = n + 2 -> n += 3"}, []string{"n := 0\n\tn += 3\n"}, []string{"n + 1", "n + 2"}},
+	{[]string{"x = x + 2 ->"}, []string{"n = n + 1"}, []string{"n + 2"}},
+	{[]string{"p.x -> p.y"}, []string{"m := y.y + y.z"}, []string{"y.x"}},
+	{[]string{"a .+ a -> double(a)"}, []string{"x._dot_add((y._dot_add(z)))"}, []string{"double"}},
+}
+
+
+func TestRewrite(t *testing.T) {
+	for _, test := range rewriteTests {
+		rules := make([]*Rule, len(test.rules))
+		for i, s := range test.rules {
+			r, err := ParseRule(s)
+			if err != nil {
+				t.Fatalf("ParseRule(%q): %v", s, err)
+			}
+			rules[i] = r
+		}
+		f, err := parser.ParseFile("", rewriteSrc, 0)
+		if err != nil {
+			t.Fatalf("ParseFile: %v", err)
+		}
+		Rewrite(f, rules, nil)
+		var buf bytes.Buffer
+		if err := printer.Fprint(&buf, f); err != nil {
+			t.Fatalf("Fprint: %v", err)
+		}
+		for _, s := range test.expected {
+			if strings.Index(buf.String(), s) < 0 {
+				t.Errorf("rewriting with %v: expected %q in\n%s", test.rules, s, buf.String())
+			}
+		}
+		for _, s := range test.absent {
+			if strings.Index(buf.String(), s) >= 0 {
+				t.Errorf("rewriting with %v: unexpected %q in\n%s", test.rules, s, buf.String())
+			}
+		}
+	}
+}
+
+
+func TestParseRule(t *testing.T) {
+	for _, s := range []string{"a .+ b", "a .+ b -> x =", "x = 1 -> y ="} {
+		if _, err := ParseRule(s); err == nil {
+			t.Errorf("ParseRule(%q) succeeded", s)
+		}
+	}
+}
+
+
+const rewrittenSrc = `package p
+
+func f(x, y, z Vec) Vec {
+	n := 0
+	n += 3
+	m := y.x + y.z
+	return add(x, (add(y, z)))
+}
+`
+
+
+func TestRewriteDiff(t *testing.T) {
+	f, err := parser.ParseFile("", rewriteSrc, 0)
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+	expected, err := parser.ParseFile("", rewrittenSrc, 0)
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+	sources := []string{"a .+ b -> add(a, b)", "n = n + 1; n = n + 2 -> n += 3"}
+	rules := make([]*Rule, len(sources))
+	for i, s := range sources {
+		if rules[i], err = ParseRule(s); err != nil {
+			t.Fatalf("ParseRule(%q): %v", s, err)
+		}
+	}
+	Rewrite(f, rules, nil)
+	for _, d := range Diff(expected, f, 0) {
+		t.Error(d)
+	}
+}
+
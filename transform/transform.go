@@ -5,7 +5,7 @@
 package transform
 
 import (
-	"fmt"
+	"container/vector"
 	"go/ast"
 )
 
@@ -41,14 +41,118 @@ func walkBlockStmt(v Visitor, b *ast.BlockStmt) *ast.BlockStmt {
 }
 
 
+func walkBasicLit(v Visitor, x *ast.BasicLit) *ast.BasicLit {
+	if x != nil {
+		return Walk(v, x).(*ast.BasicLit)
+	}
+	return nil
+}
+
+
+func walkFieldList(v Visitor, l *ast.FieldList) *ast.FieldList {
+	if l != nil {
+		return Walk(v, l).(*ast.FieldList)
+	}
+	return nil
+}
+
+
+func walkFuncType(v Visitor, t *ast.FuncType) *ast.FuncType {
+	if t != nil {
+		return Walk(v, t).(*ast.FuncType)
+	}
+	return nil
+}
+
+
+func walkCallExpr(v Visitor, x *ast.CallExpr) *ast.CallExpr {
+	if x != nil {
+		return Walk(v, x).(*ast.CallExpr)
+	}
+	return nil
+}
+
+
+func walkExpr(v Visitor, x ast.Expr) ast.Expr {
+	if x != nil {
+		return Walk(v, x).(ast.Expr)
+	}
+	return nil
+}
+
+
+// walkStmt walks a statement that is not within a list, so it may be
+// replaced by a []ast.Stmt only if that holds a single statement.
+func walkStmt(v Visitor, s ast.Stmt) ast.Stmt {
+	if s != nil {
+		r := Walk(v, s)
+		if l, ok := r.([]ast.Stmt); ok {
+			if len(l) != 1 {
+				panic("transform.Walk: cannot splice statements outside a statement list")
+			}
+			return l[0]
+		}
+		return r.(ast.Stmt)
+	}
+	return nil
+}
+
+
+// walkStmtList walks a list of statements, splicing the statements of
+// any []ast.Stmt that v returns into the list in place of the
+// statement it replaces.
+func walkStmtList(v Visitor, list []ast.Stmt) []ast.Stmt {
+	var spliced *vector.Vector
+	for i, x := range list {
+		r := Walk(v, x)
+		l, ok := r.([]ast.Stmt)
+		if ok && spliced == nil {
+			spliced = new(vector.Vector)
+			for _, s := range list[0:i] {
+				spliced.Push(s)
+			}
+		}
+		switch {
+		case ok:
+			for _, s := range l {
+				spliced.Push(s)
+			}
+		case spliced != nil:
+			spliced.Push(r.(ast.Stmt))
+		default:
+			list[i] = r.(ast.Stmt)
+		}
+	}
+	if spliced == nil {
+		return list
+	}
+	out := make([]ast.Stmt, spliced.Len())
+	for i, s := range *spliced {
+		out[i] = s.(ast.Stmt)
+	}
+	return out
+}
+
+
 // Walk traverses an AST in depth-first order: If node != nil, it
 // invokes v.Visit(node). If the modifiednode returned by
 // v.Visit(node) is not nil, Walk returns a node with this node
 // modified.
 //
-// Walk may be called with any of the named ast node types. It also
+// Walk may be called with any of the named ast node types, and with
+// any type registered with RegisterNode. It also
 // accepts arguments of type []*ast.Field, []*ast.Ident, []ast.Expr, []ast.Stmt and []ast.Decl;
-// the respective children are the slice elements.
+// the respective children are the slice elements.  Walk panics with
+// an UnknownNodeError if it meets a node of any other type; TryWalk
+// returns the error instead.  Optional children
+// that are absent, such as the Init of a SwitchStmt or the End of a
+// SliceExpr, are skipped: v.Visit is never called with a nil node.
+//
+// A Visitor may replace a statement by a []ast.Stmt.  Within the List
+// of a BlockStmt or the Body of a case or comm clause, the statements
+// are spliced into the list in place of the one they replace, so a
+// transform may turn one statement into several, or into none.
+// Elsewhere the slice must hold exactly one statement.
 //
 func Walk(v Visitor, node interface{}) (modifiednode interface{}) {
 	if node == nil {
@@ -74,8 +178,8 @@ func Walk(v Visitor, node interface{}) (modifiednode interface{}) {
 	case *ast.Field:
 		n.Doc = walkCommentGroup(v, n.Doc)
 		n.Names = Walk(v, n.Names).([]*ast.Ident)
-		n.Type = Walk(v, n.Type).(ast.Expr)
-		n.Tag = Walk(v, n.Tag).(*ast.BasicLit)
+		n.Type = walkExpr(v, n.Type)
+		n.Tag = walkBasicLit(v, n.Tag)
 		n.Comment = walkCommentGroup(v, n.Comment)
 
 	case *ast.FieldList:
@@ -84,82 +188,79 @@ func Walk(v Visitor, node interface{}) (modifiednode interface{}) {
 		}
 
 	// ast.Expressions
-	case *ast.BadExpr, *ast.Ident, *ast.Ellipsis, *ast.BasicLit:
+	case *ast.BadExpr, *ast.Ident, *ast.BasicLit:
 		// nothing to do
 
+	case *ast.Ellipsis:
+		n.Elt = walkExpr(v, n.Elt)
+
 	case *ast.FuncLit:
-		if n != nil {
-			n.Type = Walk(v, n.Type).(*ast.FuncType)
-		}
+		n.Type = walkFuncType(v, n.Type)
 		n.Body = walkBlockStmt(v, n.Body)
 
 	case *ast.CompositeLit:
-		n.Type = Walk(v, n.Type).(ast.Expr)
+		n.Type = walkExpr(v, n.Type)
 		n.Elts = Walk(v, n.Elts).([]ast.Expr)
 
 	case *ast.ParenExpr:
-		n.X = Walk(v, n.X).(ast.Expr)
+		n.X = walkExpr(v, n.X)
 
 	case *ast.SelectorExpr:
-		n.X = Walk(v, n.X).(ast.Expr)
+		n.X = walkExpr(v, n.X)
 		n.Sel = walkIdent(v, n.Sel)
 
 	case *ast.IndexExpr:
-		n.X = Walk(v, n.X).(ast.Expr)
-		n.Index = Walk(v, n.Index).(ast.Expr)
+		n.X = walkExpr(v, n.X)
+		n.Index = walkExpr(v, n.Index)
 
 	case *ast.SliceExpr:
-		n.X = Walk(v, n.X).(ast.Expr)
-		n.Index = Walk(v, n.Index).(ast.Expr)
-		n.End = Walk(v, n.End).(ast.Expr)
+		n.X = walkExpr(v, n.X)
+		n.Index = walkExpr(v, n.Index)
+		n.End = walkExpr(v, n.End)
 
 	case *ast.TypeAssertExpr:
-		n.X = Walk(v, n.X).(ast.Expr)
-		n.Type = Walk(v, n.Type).(ast.Expr)
+		n.X = walkExpr(v, n.X)
+		n.Type = walkExpr(v, n.Type)
 
 	case *ast.CallExpr:
-		n.Fun = Walk(v, n.Fun).(ast.Expr)
+		n.Fun = walkExpr(v, n.Fun)
 		n.Args = Walk(v, n.Args).([]ast.Expr)
 
 	case *ast.StarExpr:
-		n.X = Walk(v, n.X).(ast.Expr)
+		n.X = walkExpr(v, n.X)
 
 	case *ast.UnaryExpr:
-		n.X = Walk(v, n.X).(ast.Expr)
+		n.X = walkExpr(v, n.X)
 
 	case *ast.BinaryExpr:
-		n.X = Walk(v, n.X).(ast.Expr)
-		n.Y = Walk(v, n.Y).(ast.Expr)
+		n.X = walkExpr(v, n.X)
+		n.Y = walkExpr(v, n.Y)
 
 	case *ast.KeyValueExpr:
-		n.Key = Walk(v, n.Key).(ast.Expr)
-		n.Value = Walk(v, n.Value).(ast.Expr)
+		n.Key = walkExpr(v, n.Key)
+		n.Value = walkExpr(v, n.Value)
 
 	// Types
 	case *ast.ArrayType:
-		if n.Len != nil {
-			n.Len = Walk(v, n.Len).(ast.Expr)
-		}
-		n.Elt = Walk(v, n.Elt).(ast.Expr)
+		n.Len = walkExpr(v, n.Len)
+		n.Elt = walkExpr(v, n.Elt)
 
 	case *ast.StructType:
-		n.Fields = Walk(v, n.Fields).(*ast.FieldList)
+		n.Fields = walkFieldList(v, n.Fields)
 
 	case *ast.FuncType:
-		n.Params = Walk(v, n.Params).(*ast.FieldList)
-		if n.Results != nil {
-			n.Results = Walk(v, n.Results).(*ast.FieldList)
-		}
+		n.Params = walkFieldList(v, n.Params)
+		n.Results = walkFieldList(v, n.Results)
 
 	case *ast.InterfaceType:
-		n.Methods = Walk(v, n.Methods).(*ast.FieldList)
+		n.Methods = walkFieldList(v, n.Methods)
 
 	case *ast.MapType:
-		n.Key = Walk(v, n.Key).(ast.Expr)
-		n.Value = Walk(v, n.Value).(ast.Expr)
+		n.Key = walkExpr(v, n.Key)
+		n.Value = walkExpr(v, n.Value)
 
 	case *ast.ChanType:
-		n.Value = Walk(v, n.Value).(ast.Expr)
+		n.Value = walkExpr(v, n.Value)
 
 	// Statements
 	case *ast.BadStmt:
@@ -173,27 +274,23 @@ func Walk(v Visitor, node interface{}) (modifiednode interface{}) {
 
 	case *ast.LabeledStmt:
 		n.Label = walkIdent(v, n.Label)
-		n.Stmt = Walk(v, n.Stmt).(ast.Stmt)
+		n.Stmt = walkStmt(v, n.Stmt)
 
 	case *ast.ExprStmt:
-		n.X = Walk(v, n.X).(ast.Expr)
+		n.X = walkExpr(v, n.X)
 
 	case *ast.IncDecStmt:
-		n.X = Walk(v, n.X).(ast.Expr)
+		n.X = walkExpr(v, n.X)
 
 	case *ast.AssignStmt:
 		n.Lhs = Walk(v, n.Lhs).([]ast.Expr)
 		n.Rhs = Walk(v, n.Rhs).([]ast.Expr)
 
 	case *ast.GoStmt:
-		if n.Call != nil {
-			n.Call = Walk(v, n.Call).(*ast.CallExpr)
-		}
+		n.Call = walkCallExpr(v, n.Call)
 
 	case *ast.DeferStmt:
-		if n.Call != nil {
-			n.Call = Walk(v, n.Call).(*ast.CallExpr)
-		}
+		n.Call = walkCallExpr(v, n.Call)
 
 	case *ast.ReturnStmt:
 		n.Results = Walk(v, n.Results).([]ast.Expr)
@@ -205,22 +302,18 @@ func Walk(v Visitor, node interface{}) (modifiednode interface{}) {
 		n.List = Walk(v, n.List).([]ast.Stmt)
 
 	case *ast.IfStmt:
-		if n.Init != nil {
-			n.Init = Walk(v, n.Init).(ast.Stmt)
-		}
-		n.Cond = Walk(v, n.Cond).(ast.Expr)
+		n.Init = walkStmt(v, n.Init)
+		n.Cond = walkExpr(v, n.Cond)
 		n.Body = walkBlockStmt(v, n.Body)
-		if n.Else != nil {
-			n.Else = Walk(v, n.Else).(ast.Stmt)
-		}
+		n.Else = walkStmt(v, n.Else)
 
 	case *ast.CaseClause:
 		n.Values = Walk(v, n.Values).([]ast.Expr)
 		n.Body = Walk(v, n.Body).([]ast.Stmt)
 
 	case *ast.SwitchStmt:
-		n.Init = Walk(v, n.Init).(ast.Stmt)
-		n.Tag = Walk(v, n.Tag).(ast.Expr)
+		n.Init = walkStmt(v, n.Init)
+		n.Tag = walkExpr(v, n.Tag)
 		n.Body = walkBlockStmt(v, n.Body)
 
 	case *ast.TypeCaseClause:
@@ -228,52 +321,48 @@ func Walk(v Visitor, node interface{}) (modifiednode interface{}) {
 		n.Body = Walk(v, n.Body).([]ast.Stmt)
 
 	case *ast.TypeSwitchStmt:
-		n.Init = Walk(v, n.Init).(ast.Stmt)
-		n.Assign = Walk(v, n.Assign).(ast.Stmt)
+		n.Init = walkStmt(v, n.Init)
+		n.Assign = walkStmt(v, n.Assign)
 		n.Body = walkBlockStmt(v, n.Body)
 
 	case *ast.CommClause:
-		n.Lhs = Walk(v, n.Lhs).(ast.Expr)
-		n.Rhs = Walk(v, n.Rhs).(ast.Expr)
+		n.Lhs = walkExpr(v, n.Lhs)
+		n.Rhs = walkExpr(v, n.Rhs)
 		n.Body = Walk(v, n.Body).([]ast.Stmt)
 
 	case *ast.SelectStmt:
 		n.Body = walkBlockStmt(v, n.Body)
 
 	case *ast.ForStmt:
-		n.Init = Walk(v, n.Init).(ast.Stmt)
-		n.Cond = Walk(v, n.Cond).(ast.Expr)
-		n.Post = Walk(v, n.Post).(ast.Stmt)
+		n.Init = walkStmt(v, n.Init)
+		n.Cond = walkExpr(v, n.Cond)
+		n.Post = walkStmt(v, n.Post)
 		n.Body = walkBlockStmt(v, n.Body)
 
 	case *ast.RangeStmt:
-		n.Key = Walk(v, n.Key).(ast.Expr)
-		n.Value = Walk(v, n.Value).(ast.Expr)
-		n.X = Walk(v, n.X).(ast.Expr)
+		n.Key = walkExpr(v, n.Key)
+		n.Value = walkExpr(v, n.Value)
+		n.X = walkExpr(v, n.X)
 		n.Body = walkBlockStmt(v, n.Body)
 
 	// ast.Declarations
 	case *ast.ImportSpec:
 		n.Doc = walkCommentGroup(v, n.Doc)
 		n.Name = walkIdent(v, n.Name)
-		n.Path = Walk(v, n.Path).(*ast.BasicLit)
+		n.Path = walkBasicLit(v, n.Path)
 		n.Comment = walkCommentGroup(v, n.Comment)
 
 	case *ast.ValueSpec:
 		n.Doc = walkCommentGroup(v, n.Doc)
 		n.Names = Walk(v, n.Names).([]*ast.Ident)
-		if t := Walk(v, n.Type); t != nil {
-			n.Type = t.(ast.Expr)
-		} else {
-			n.Type = nil
-		}
+		n.Type = walkExpr(v, n.Type)
 		n.Values = Walk(v, n.Values).([]ast.Expr)
 		n.Comment = walkCommentGroup(v, n.Comment)
 
 	case *ast.TypeSpec:
 		n.Doc = walkCommentGroup(v, n.Doc)
 		n.Name = walkIdent(v, n.Name)
-		n.Type = Walk(v, n.Type).(ast.Expr)
+		n.Type = walkExpr(v, n.Type)
 		n.Comment = walkCommentGroup(v, n.Comment)
 
 	case *ast.BadDecl:
@@ -287,13 +376,9 @@ func Walk(v Visitor, node interface{}) (modifiednode interface{}) {
 
 	case *ast.FuncDecl:
 		n.Doc = walkCommentGroup(v, n.Doc)
-		if n.Recv != nil {
-			n.Recv = Walk(v, n.Recv).(*ast.FieldList)
-		}
+		n.Recv = walkFieldList(v, n.Recv)
 		n.Name = walkIdent(v, n.Name)
-		if n.Type != nil {
-			n.Type = Walk(v, n.Type).(*ast.FuncType)
-		}
+		n.Type = walkFuncType(v, n.Type)
 		n.Body = walkBlockStmt(v, n.Body)
 
 	// Files and packages
@@ -321,9 +406,7 @@ func Walk(v Visitor, node interface{}) (modifiednode interface{}) {
 		}
 
 	case []ast.Stmt:
-		for i, x := range n {
-			n[i] = Walk(v, x).(ast.Stmt)
-		}
+		return walkStmtList(v, n)
 
 	case []ast.Decl:
 		for i, x := range n {
@@ -331,8 +414,7 @@ func Walk(v Visitor, node interface{}) (modifiednode interface{}) {
 		}
 
 	default:
-		fmt.Printf("ast.Walk: unexpected type %T", n)
-		panic("ast.Walk")
+		nodeType(n).Walk(v, n)
 	}
 
 	return node
@@ -0,0 +1,268 @@
+// Copyright 2010 David Roundy, roundyd@physics.oregonstate.edu.
+// All rights reserved.
+
+package transform
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/printer"
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+	"github.com/droundy/go-crazy/parser"
+)
+
+
+// corpus holds at least one of every kind of node, with each of the
+// optional children both present and absent.
+const corpus = `// Package corpus exercises every kind of node.
+package corpus
+
+import (
+	"fmt"
+	. "os"
+)
+
+type T struct {
+	a, b int "tag"
+	*T
+}
+
+type I interface {
+	M(x ...int) (int, bool)
+	fmt.Stringer
+}
+
+var v, w = 1, 2
+var m map[string]chan<- int
+
+const (
+	c0 = iota
+	c1
+)
+
+func (t *T) M(x ...int) (n int, ok bool) {
+	return
+}
+
+func declared()
+
+func f(a []int, i interface{}, ch chan int) {
+	var x [3]int
+	_ = [...]string{"a", "b"}
+	_ = []T{T{a: 1}, {}}
+	_ = a[:2]
+	_ = a[1:]
+	_ = a[0:1]
+	_ = x[0]
+	_ = i.(*T)
+	switch i.(type) {
+	case int, bool:
+	default:
+	}
+	switch y := i.(type) {
+	}
+	switch {
+	case v > w:
+		fallthrough
+	default:
+	}
+	switch y := v; y {
+	case 1:
+	}
+	for {
+		break
+	}
+L:
+	for k := range a {
+		if k > 2 {
+			continue L
+		} else if k < 0 {
+			goto L
+		} else {
+		}
+	}
+	for k, e := range a {
+		_, _ = k, e
+	}
+	for j := 0; j < 10; j++ {
+	}
+	for v < w {
+	}
+	select {
+	case ch <- 1:
+	case y := <-ch:
+		_ = y
+	case <-ch:
+	default:
+	}
+	go func() {}()
+	defer fmt.Println(-v, *(&v), (v))
+	;
+	{
+	}
+	_ = a .+ a
+	v += 1
+	_ = Args
+}
+`
+
+
+// nodeTypes are the types of the nodes that should be found in
+// corpus.
+var nodeTypes = []string{
+	"*ast.Comment", "*ast.CommentGroup", "*ast.Field", "*ast.FieldList",
+	"*ast.Ident", "*ast.Ellipsis", "*ast.BasicLit", "*ast.FuncLit",
+	"*ast.CompositeLit", "*ast.ParenExpr", "*ast.SelectorExpr",
+	"*ast.IndexExpr", "*ast.SliceExpr", "*ast.TypeAssertExpr",
+	"*ast.CallExpr", "*ast.StarExpr", "*ast.UnaryExpr", "*ast.BinaryExpr",
+	"*ast.KeyValueExpr", "*ast.ArrayType", "*ast.StructType",
+	"*ast.FuncType", "*ast.InterfaceType", "*ast.MapType", "*ast.ChanType",
+	"*ast.DeclStmt", "*ast.EmptyStmt", "*ast.LabeledStmt", "*ast.ExprStmt",
+	"*ast.IncDecStmt", "*ast.AssignStmt", "*ast.GoStmt", "*ast.DeferStmt",
+	"*ast.ReturnStmt", "*ast.BranchStmt", "*ast.BlockStmt", "*ast.IfStmt",
+	"*ast.CaseClause", "*ast.SwitchStmt", "*ast.TypeCaseClause",
+	"*ast.TypeSwitchStmt", "*ast.CommClause", "*ast.SelectStmt",
+	"*ast.ForStmt", "*ast.RangeStmt", "*ast.ImportSpec", "*ast.ValueSpec",
+	"*ast.TypeSpec", "*ast.GenDecl", "*ast.FuncDecl", "*ast.File",
+	"*ast.Package",
+}
+
+
+// A typeRecorder records the type of every node it visits, and
+// reports an error if it is given a nil node.
+type typeRecorder struct {
+	t    *testing.T
+	seen map[string]bool
+}
+
+func (v *typeRecorder) Visit(node interface{}) interface{} {
+	if p, ok := reflect.NewValue(node).(*reflect.PtrValue); ok && p.IsNil() {
+		v.t.Errorf("Visit called with nil %T", node)
+	}
+	v.seen[fmt.Sprintf("%T", node)] = true
+	return nil
+}
+
+
+func TestWalkCorpus(t *testing.T) {
+	f, err := parser.ParseFile("corpus.go", corpus, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+	pkg := &ast.Package{"corpus", nil, map[string]*ast.File{"corpus.go": f}}
+	v := &typeRecorder{t, make(map[string]bool)}
+	if Walk(v, pkg) != pkg {
+		t.Errorf("Walk did not return the package it was given")
+	}
+	for _, name := range nodeTypes {
+		if !v.seen[name] {
+			t.Errorf("no %s visited", name)
+		}
+	}
+}
+
+
+// TestWalkNil walks nodes built by hand, with every optional child
+// left out.
+func TestWalkNil(t *testing.T) {
+	a := ast.NewIdent("a")
+	nodes := []interface{}{
+		&ast.Field{},
+		&ast.FieldList{},
+		&ast.Ellipsis{},
+		&ast.FuncLit{},
+		&ast.CompositeLit{},
+		&ast.SliceExpr{X: a},
+		&ast.TypeAssertExpr{X: a},
+		&ast.CallExpr{Fun: a},
+		&ast.KeyValueExpr{},
+		&ast.ArrayType{},
+		&ast.StructType{},
+		&ast.FuncType{},
+		&ast.InterfaceType{},
+		&ast.LabeledStmt{},
+		&ast.GoStmt{},
+		&ast.DeferStmt{},
+		&ast.ReturnStmt{},
+		&ast.BranchStmt{},
+		&ast.BlockStmt{},
+		&ast.IfStmt{},
+		&ast.CaseClause{},
+		&ast.SwitchStmt{},
+		&ast.TypeCaseClause{},
+		&ast.TypeSwitchStmt{},
+		&ast.CommClause{},
+		&ast.SelectStmt{},
+		&ast.ForStmt{},
+		&ast.RangeStmt{X: a},
+		&ast.ImportSpec{},
+		&ast.ValueSpec{},
+		&ast.TypeSpec{},
+		&ast.GenDecl{},
+		&ast.FuncDecl{},
+		&ast.File{},
+		&ast.Package{},
+	}
+	for _, n := range nodes {
+		v := &typeRecorder{t, make(map[string]bool)}
+		if Walk(v, n) != n {
+			t.Errorf("Walk did not return the %T it was given", n)
+		}
+	}
+}
+
+
+const spliceSrc = `package p
+
+func f(x int, c chan int) {
+	x++
+	x--
+	switch x {
+	case 1:
+		x++
+	}
+	select {
+	case <-c:
+		x--
+		x++
+	}
+}
+`
+
+
+// A splicer doubles every x++ and deletes every x--.
+type splicer struct{}
+
+func (v splicer) Visit(node interface{}) interface{} {
+	if s, ok := node.(*ast.IncDecStmt); ok {
+		if s.Tok == token.INC {
+			return []ast.Stmt{s, s}
+		}
+		return []ast.Stmt{}
+	}
+	return nil
+}
+
+
+func TestWalkSplice(t *testing.T) {
+	f, err := parser.ParseFile("", spliceSrc, 0)
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+	Walk(splicer{}, f)
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, f); err != nil {
+		t.Fatalf("Fprint: %v", err)
+	}
+	out := buf.String()
+	if n := strings.Count(out, "x++"); n != 6 {
+		t.Errorf("found %d x++, want 6:\n%s", n, out)
+	}
+	if strings.Index(out, "x--") >= 0 {
+		t.Errorf("x-- was not deleted:\n%s", out)
+	}
+}
@@ -0,0 +1,609 @@
+// Copyright 2010 David Roundy, roundyd@physics.oregonstate.edu.
+// All rights reserved.
+
+package transform
+
+import (
+	"container/vector"
+	"go/ast"
+	"go/token"
+)
+
+
+// Types records the types of the expressions of an AST, as inferred
+// by InferTypes.  A type is given as a type expression: a named type
+// as an identifier referring to its declaration, a predeclared type
+// as an unresolved identifier such as float64, and any other type as
+// the literal denoting it.
+//
+// The inference is lightweight.  It follows declarations, literals,
+// conversions, calls, fields, methods and the usual operators, but
+// knows nothing of other packages, and wherever it would need to, the
+// type is unknown.
+type Types struct {
+	res     *Resolution
+	sources map[*ast.Ident]*typeSource          // how each declared name gets its type
+	named   map[string]*ast.TypeSpec            // the top-level types, by name
+	methods map[string]map[string]*ast.FuncDecl // the methods of each top-level type
+	types   map[ast.Expr]ast.Expr               // the types inferred so far
+	busy    map[*ast.Ident]bool                 // the names whose types are being inferred
+}
+
+
+// A typeSource tells how a declared name gets its type: from the type
+// it is declared with, from the index-th of the count values of an
+// expression, or from a range clause.  A type name has its
+// declaration instead.
+type typeSource struct {
+	typ      ast.Expr
+	value    ast.Expr
+	index    int
+	count    int
+	rng      *ast.RangeStmt
+	spec     *ast.TypeSpec
+	constant bool
+}
+
+
+// InferTypes returns the types of the expressions within node, which
+// should be a File or a Package.  The types are inferred as they are
+// asked for, so node must not be modified in the meantime, except by
+// replacing expressions whose types have already been asked for.
+func InferTypes(node interface{}) *Types {
+	t := &Types{
+		res:     Resolve(node),
+		sources: make(map[*ast.Ident]*typeSource),
+		named:   make(map[string]*ast.TypeSpec),
+		methods: make(map[string]map[string]*ast.FuncDecl),
+		types:   make(map[ast.Expr]ast.Expr),
+		busy:    make(map[*ast.Ident]bool),
+	}
+	Walk(&typeCollector{t}, node)
+	return t
+}
+
+
+type typeCollector struct {
+	t *Types
+}
+
+
+func (v *typeCollector) Visit(node interface{}) interface{} {
+	t := v.t
+	switch n := node.(type) {
+	case *ast.File:
+		for _, d := range n.Decls {
+			if g, ok := d.(*ast.GenDecl); ok && g.Tok == token.TYPE {
+				for _, s := range g.Specs {
+					s := s.(*ast.TypeSpec)
+					t.named[s.Name.Name] = s
+				}
+			}
+		}
+
+	case *ast.FuncDecl:
+		if n.Recv == nil {
+			t.sources[n.Name] = &typeSource{typ: n.Type}
+		} else if len(n.Recv.List) == 1 {
+			if id, ok := derefType(n.Recv.List[0].Type).(*ast.Ident); ok {
+				if t.methods[id.Name] == nil {
+					t.methods[id.Name] = make(map[string]*ast.FuncDecl)
+				}
+				t.methods[id.Name][n.Name.Name] = n
+			}
+		}
+
+	case *ast.Field:
+		typ := n.Type
+		if e, ok := typ.(*ast.Ellipsis); ok {
+			typ = &ast.ArrayType{Elt: e.Elt}
+		}
+		for _, id := range n.Names {
+			t.sources[id] = &typeSource{typ: typ}
+		}
+
+	case *ast.GenDecl:
+		var typ ast.Expr
+		var values []ast.Expr
+		for _, s := range n.Specs {
+			switch s := s.(type) {
+			case *ast.TypeSpec:
+				t.sources[s.Name] = &typeSource{spec: s}
+			case *ast.ValueSpec:
+				if s.Type != nil || s.Values != nil || n.Tok != token.CONST {
+					// constants without either repeat the last
+					typ, values = s.Type, s.Values
+				}
+				for i, id := range s.Names {
+					src := &typeSource{typ: typ, constant: n.Tok == token.CONST}
+					src.value, src.index, src.count = ith(values, i, len(s.Names))
+					t.sources[id] = src
+				}
+			}
+		}
+
+	case *ast.AssignStmt:
+		if n.Tok == token.DEFINE {
+			for i, x := range n.Lhs {
+				if id, ok := x.(*ast.Ident); ok && t.res.Decl(id) == id {
+					src := new(typeSource)
+					src.value, src.index, src.count = ith(n.Rhs, i, len(n.Lhs))
+					t.sources[id] = src
+				}
+			}
+		}
+
+	case *ast.RangeStmt:
+		if n.Tok == token.DEFINE {
+			if id, ok := n.Key.(*ast.Ident); ok {
+				t.sources[id] = &typeSource{rng: n, index: 0}
+			}
+			if id, ok := n.Value.(*ast.Ident); ok {
+				t.sources[id] = &typeSource{rng: n, index: 1}
+			}
+		}
+	}
+	return nil
+}
+
+
+// ith returns the expression whose value the i-th of count names is
+// assigned from values, together with which of its values it is and
+// how many it has.
+func ith(values []ast.Expr, i, count int) (value ast.Expr, index, n int) {
+	switch {
+	case len(values) == count:
+		return values[i], 0, 1
+	case len(values) == 1:
+		return values[0], i, count
+	}
+	return nil, 0, 0
+}
+
+
+func derefType(x ast.Expr) ast.Expr {
+	if p, ok := x.(*ast.StarExpr); ok {
+		return p.X
+	}
+	return x
+}
+
+
+// TypeOf returns the type of x, or nil if it is not known.
+func (t *Types) TypeOf(x ast.Expr) ast.Expr {
+	if typ, ok := t.types[x]; ok {
+		return typ
+	}
+	typ := t.infer(x)
+	t.types[x] = typ
+	return typ
+}
+
+
+// SetType records that x is of type typ, for an expression whose type
+// can't be inferred, such as a call of a method of another package,
+// or which replaces one whose type has not yet been asked for.
+func (t *Types) SetType(x, typ ast.Expr) {
+	t.types[x] = typ
+}
+
+
+func (t *Types) infer(x ast.Expr) ast.Expr {
+	switch x := x.(type) {
+	case *ast.Ident:
+		if d := t.res.Decl(x); d != nil {
+			return t.declType(d)
+		}
+		switch x.Name {
+		case "true", "false":
+			return ast.NewIdent("bool")
+		case "iota":
+			return ast.NewIdent("int")
+		}
+
+	case *ast.BasicLit:
+		switch x.Kind {
+		case token.INT, token.CHAR:
+			return ast.NewIdent("int")
+		case token.FLOAT:
+			return ast.NewIdent("float")
+		case token.IMAG:
+			return ast.NewIdent("complex")
+		case token.STRING:
+			return ast.NewIdent("string")
+		}
+
+	case *ast.CompositeLit:
+		return x.Type
+
+	case *ast.FuncLit:
+		return x.Type
+
+	case *ast.ParenExpr:
+		return t.TypeOf(x.X)
+
+	case *ast.SelectorExpr:
+		typ := t.TypeOf(x.X)
+		if f := t.fieldType(typ, x.Sel.Name, 0); f != nil {
+			return f
+		}
+		if m := t.MethodType(typ, x.Sel.Name); m != nil {
+			return m
+		}
+
+	case *ast.IndexExpr:
+		switch u := t.Underlying(derefType(t.Underlying(t.TypeOf(x.X)))).(type) {
+		case *ast.ArrayType:
+			return u.Elt
+		case *ast.MapType:
+			return u.Value
+		case *ast.Ident:
+			if u.Name == "string" {
+				return ast.NewIdent("byte")
+			}
+		}
+
+	case *ast.SliceExpr:
+		typ := t.TypeOf(x.X)
+		if a, ok := t.Underlying(derefType(t.Underlying(typ))).(*ast.ArrayType); ok && a.Len != nil {
+			return &ast.ArrayType{Elt: a.Elt}
+		}
+		return typ
+
+	case *ast.TypeAssertExpr:
+		return x.Type
+
+	case *ast.CallExpr:
+		return t.callType(x)
+
+	case *ast.StarExpr:
+		if p, ok := t.Underlying(t.TypeOf(x.X)).(*ast.StarExpr); ok {
+			return p.X
+		}
+
+	case *ast.UnaryExpr:
+		switch x.Op {
+		case token.AND:
+			if typ := t.TypeOf(x.X); typ != nil {
+				return &ast.StarExpr{X: typ}
+			}
+			return nil
+		case token.NOT:
+			return ast.NewIdent("bool")
+		case token.ARROW:
+			if c, ok := t.Underlying(t.TypeOf(x.X)).(*ast.ChanType); ok {
+				return c.Value
+			}
+			return nil
+		}
+		return t.TypeOf(x.X)
+
+	case *ast.BinaryExpr:
+		switch x.Op {
+		case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ, token.LAND, token.LOR:
+			return ast.NewIdent("bool")
+		case token.SHL, token.SHR:
+			return t.TypeOf(x.X)
+		}
+		if t.Untyped(x.X) {
+			return t.TypeOf(x.Y)
+		}
+		return t.TypeOf(x.X)
+	}
+	return nil
+}
+
+
+// declType returns the type of the name declared by id.
+func (t *Types) declType(id *ast.Ident) ast.Expr {
+	s := t.sources[id]
+	if s == nil || t.busy[id] {
+		return nil
+	}
+	t.busy[id] = true
+	defer func() { t.busy[id] = false, false }()
+	switch {
+	case s.typ != nil:
+		return s.typ
+	case s.value != nil:
+		return t.valueType(s.value, s.index, s.count)
+	case s.rng != nil:
+		switch u := t.Underlying(derefType(t.Underlying(t.TypeOf(s.rng.X)))).(type) {
+		case *ast.ArrayType:
+			if s.index == 0 {
+				return ast.NewIdent("int")
+			}
+			return u.Elt
+		case *ast.MapType:
+			if s.index == 0 {
+				return u.Key
+			}
+			return u.Value
+		case *ast.ChanType:
+			return u.Value
+		case *ast.Ident:
+			if u.Name == "string" {
+				return ast.NewIdent("int")
+			}
+		}
+	}
+	return nil
+}
+
+
+// valueType returns the type of the index-th of the count values of
+// x.
+func (t *Types) valueType(x ast.Expr, index, count int) ast.Expr {
+	if count == 1 {
+		return t.TypeOf(x)
+	}
+	for {
+		p, ok := x.(*ast.ParenExpr)
+		if !ok {
+			break
+		}
+		x = p.X
+	}
+	switch y := x.(type) {
+	case *ast.CallExpr:
+		if results := t.results(y); index < len(results) {
+			return results[index]
+		}
+		return nil
+	case *ast.IndexExpr, *ast.TypeAssertExpr, *ast.UnaryExpr:
+		// v, ok = m[k], x.(T) or <-c
+		if index == 1 {
+			return ast.NewIdent("bool")
+		}
+	}
+	return t.TypeOf(x)
+}
+
+
+// callType returns the type of the call or conversion x.
+func (t *Types) callType(x *ast.CallExpr) ast.Expr {
+	if typ := t.typeExpr(x.Fun); typ != nil {
+		return typ
+	}
+	if id, ok := x.Fun.(*ast.Ident); ok && t.res.Decl(id) == nil {
+		switch id.Name {
+		case "len", "cap", "copy":
+			return ast.NewIdent("int")
+		case "new":
+			if len(x.Args) == 1 {
+				return &ast.StarExpr{X: x.Args[0]}
+			}
+		case "make":
+			if len(x.Args) > 0 {
+				return x.Args[0]
+			}
+		}
+	}
+	if results := t.results(x); len(results) == 1 {
+		return results[0]
+	}
+	return nil
+}
+
+
+// results returns the types of the results of the call x, with one
+// element for each result.
+func (t *Types) results(x *ast.CallExpr) []ast.Expr {
+	f, ok := t.Underlying(t.TypeOf(x.Fun)).(*ast.FuncType)
+	if !ok || f.Results == nil {
+		return nil
+	}
+	var types vector.Vector
+	for _, r := range f.Results.List {
+		types.Push(r.Type)
+		for i := 1; i < len(r.Names); i++ {
+			types.Push(r.Type)
+		}
+	}
+	list := make([]ast.Expr, types.Len())
+	for i, typ := range types {
+		list[i] = typ.(ast.Expr)
+	}
+	return list
+}
+
+
+// typeExpr returns x if it denotes a type, and nil otherwise.
+func (t *Types) typeExpr(x ast.Expr) ast.Expr {
+	switch y := x.(type) {
+	case *ast.Ident:
+		if t.typeSpec(y) != nil || t.res.Decl(y) == nil && predeclaredTypes[y.Name] {
+			return x
+		}
+	case *ast.ParenExpr:
+		return t.typeExpr(y.X)
+	case *ast.StarExpr:
+		if t.typeExpr(y.X) != nil {
+			return x
+		}
+	case *ast.ArrayType, *ast.MapType, *ast.ChanType, *ast.FuncType, *ast.StructType, *ast.InterfaceType:
+		return x
+	}
+	return nil
+}
+
+
+// predeclaredTypes are the names of the predeclared types.
+var predeclaredTypes = map[string]bool{
+	"bool": true, "byte": true, "complex": true, "complex64": true,
+	"complex128": true, "float": true, "float32": true, "float64": true,
+	"int": true, "int8": true, "int16": true, "int32": true, "int64": true,
+	"string": true, "uint": true, "uint8": true, "uint16": true,
+	"uint32": true, "uint64": true, "uintptr": true,
+}
+
+
+// typeSpec returns the declaration of the named type id, or nil if id
+// does not name a declared type.  An identifier that was not resolved,
+// such as one made by a transform, is taken to name the top-level
+// type of its name, if there is one.
+func (t *Types) typeSpec(id *ast.Ident) *ast.TypeSpec {
+	if d := t.res.Decl(id); d != nil {
+		if s := t.sources[d]; s != nil {
+			return s.spec
+		}
+		return nil
+	}
+	return t.named[id.Name]
+}
+
+
+// Underlying returns the underlying type of typ, which is typ itself
+// unless it is a declared type, or nil if typ is nil.
+func (t *Types) Underlying(typ ast.Expr) ast.Expr {
+	seen := make(map[*ast.TypeSpec]bool)
+	for {
+		switch x := typ.(type) {
+		case *ast.Ident:
+			s := t.typeSpec(x)
+			if s == nil {
+				return typ
+			}
+			if seen[s] {
+				return nil
+			}
+			seen[s] = true
+			typ = s.Type
+		case *ast.ParenExpr:
+			typ = x.X
+		default:
+			return typ
+		}
+	}
+	panic("unreachable")
+}
+
+
+// numericTypes are the names of the predeclared numeric types.
+var numericTypes = map[string]bool{
+	"byte": true, "complex": true, "complex64": true, "complex128": true,
+	"float": true, "float32": true, "float64": true,
+	"int": true, "int8": true, "int16": true, "int32": true, "int64": true,
+	"uint": true, "uint8": true, "uint16": true, "uint32": true, "uint64": true,
+	"uintptr": true,
+}
+
+
+// IsNumeric returns true if the underlying type of typ is a numeric
+// type.
+func (t *Types) IsNumeric(typ ast.Expr) bool {
+	id, ok := t.Underlying(typ).(*ast.Ident)
+	return ok && t.typeSpec(id) == nil && numericTypes[id.Name]
+}
+
+
+// Untyped returns true if x is an untyped constant, such as 2.5 or a
+// constant declared without a type, whose type is given by the
+// context in which it is used.
+func (t *Types) Untyped(x ast.Expr) bool {
+	switch x := x.(type) {
+	case *ast.BasicLit:
+		return true
+	case *ast.ParenExpr:
+		return t.Untyped(x.X)
+	case *ast.UnaryExpr:
+		return x.Op != token.AND && x.Op != token.ARROW && t.Untyped(x.X)
+	case *ast.BinaryExpr:
+		return t.Untyped(x.X) && t.Untyped(x.Y)
+	case *ast.Ident:
+		d := t.res.Decl(x)
+		if d == nil {
+			return x.Name == "true" || x.Name == "false" || x.Name == "iota"
+		}
+		s := t.sources[d]
+		if s == nil || !s.constant || s.typ != nil || s.value == nil || t.busy[d] {
+			return false
+		}
+		t.busy[d] = true
+		defer func() { t.busy[d] = false, false }()
+		return t.Untyped(s.value)
+	}
+	return false
+}
+
+
+// Method returns the declaration of the method name of typ, which may
+// be a named type or a pointer to one, or nil if it has no such
+// method declared in the AST.  Methods promoted from embedded fields
+// are not found.
+func (t *Types) Method(typ ast.Expr, name string) *ast.FuncDecl {
+	if p, ok := t.Underlying(typ).(*ast.StarExpr); ok {
+		typ = p.X
+	}
+	if id, ok := typ.(*ast.Ident); ok {
+		if s := t.typeSpec(id); s != nil {
+			return t.methods[s.Name.Name][name]
+		}
+	}
+	return nil
+}
+
+
+// MethodType returns the type of the method name of typ, whether
+// declared for a named type or in an interface, or nil if it has no
+// such method.
+func (t *Types) MethodType(typ ast.Expr, name string) *ast.FuncType {
+	if m := t.Method(typ, name); m != nil {
+		return m.Type
+	}
+	f, _ := t.interfaceMethod(typ, name, 0).(*ast.FuncType)
+	return f
+}
+
+
+// fieldType returns the type of the field name of typ, which may be a
+// struct type or a pointer to one, looking depth levels deep into
+// embedded fields, or nil if it has no such field.
+func (t *Types) fieldType(typ ast.Expr, name string, depth int) ast.Expr {
+	s, ok := t.Underlying(derefType(t.Underlying(typ))).(*ast.StructType)
+	if !ok || s.Fields == nil || depth > 8 {
+		return nil
+	}
+	for _, f := range s.Fields.List {
+		for _, id := range f.Names {
+			if id.Name == name {
+				return f.Type
+			}
+		}
+		if id, ok := derefType(f.Type).(*ast.Ident); ok && f.Names == nil && id.Name == name {
+			return f.Type
+		}
+	}
+	for _, f := range s.Fields.List {
+		if f.Names == nil {
+			if typ := t.fieldType(f.Type, name, depth+1); typ != nil {
+				return typ
+			}
+		}
+	}
+	return nil
+}
+
+
+// interfaceMethod returns the type of the method name of typ, an
+// interface type, looking depth levels deep into embedded interfaces,
+// or nil if it has no such method.
+func (t *Types) interfaceMethod(typ ast.Expr, name string, depth int) ast.Expr {
+	i, ok := t.Underlying(typ).(*ast.InterfaceType)
+	if !ok || i.Methods == nil || depth > 8 {
+		return nil
+	}
+	for _, m := range i.Methods.List {
+		for _, id := range m.Names {
+			if id.Name == name {
+				return m.Type
+			}
+		}
+		if m.Names == nil {
+			if typ := t.interfaceMethod(m.Type, name, depth+1); typ != nil {
+				return typ
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+// Copyright 2010 David Roundy, roundyd@physics.oregonstate.edu.
+// All rights reserved.
+
+package transform
+
+import (
+	"bytes"
+	"go/ast"
+	"go/printer"
+	"testing"
+	"github.com/droundy/go-crazy/parser"
+)
+
+
+const typesSrc = `package p
+
+type Vec []float64
+
+type Point struct {
+	X, Y float64
+	Vec
+}
+
+func (v Vec) Len() int { return len(v) }
+
+func (v Vec) .+ (w Vec) Vec { return v }
+
+type Additive interface {
+	.+ (Additive) Additive
+}
+
+func g(e Additive) {
+	sum := e .+ e .+ e
+}
+
+func pair() (int, string) { return 0, "" }
+
+const k = 2.5
+
+const (
+	a = iota
+	b
+)
+
+func f(p *Point, xs ...float64) {
+	n, s := pair()
+	q := p.Vec .+ p.Vec
+	for i, x := range xs {
+		r := x * k
+	}
+	m := map[string]Vec{}
+	v, ok := m[s]
+	z := &q
+	l := p.Vec.Len()
+	y := p.Y
+	c := []byte("c")[0]
+	h := float64(b) * 2
+	u := unknown(n)
+}
+`
+
+
+// lastUse returns the last identifier named name in f.
+func lastUse(f *ast.File, name string) *ast.Ident {
+	found := Query(f, func(m *Match) bool {
+		id, ok := m.Node.(*ast.Ident)
+		return ok && id.Name == name
+	})
+	return found[len(found)-1].Node.(*ast.Ident)
+}
+
+
+func typeString(t *testing.T, typ ast.Expr) string {
+	if typ == nil {
+		return "unknown"
+	}
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, typ); err != nil {
+		t.Fatalf("Fprint: %v", err)
+	}
+	return buf.String()
+}
+
+
+func TestInferTypes(t *testing.T) {
+	f, err := parser.ParseFile("", typesSrc, 0)
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+	types := InferTypes(f)
+	tests := []struct{ name, typ string }{
+		{"p", "*Point"},
+		{"xs", "[]float64"},
+		{"n", "int"},
+		{"s", "string"},
+		{"q", "Vec"},
+		{"i", "int"},
+		{"x", "float64"},
+		{"r", "float64"},
+		{"m", "map[string]Vec"},
+		{"v", "Vec"},
+		{"ok", "bool"},
+		{"z", "*Vec"},
+		{"l", "int"},
+		{"y", "float64"},
+		{"c", "byte"},
+		{"h", "float64"},
+		{"b", "int"},
+		{"u", "unknown"},
+		{"sum", "Additive"},
+	}
+	for _, test := range tests {
+		if typ := typeString(t, types.TypeOf(lastUse(f, test.name))); typ != test.typ {
+			t.Errorf("%s is a %s, want %s", test.name, typ, test.typ)
+		}
+	}
+
+	for name, untyped := range map[string]bool{"k": true, "b": true, "x": false, "n": false} {
+		if types.Untyped(lastUse(f, name)) != untyped {
+			t.Errorf("Untyped(%s) = %v", name, !untyped)
+		}
+	}
+	vec := types.TypeOf(lastUse(f, "v"))
+	if typ := typeString(t, types.Underlying(vec)); typ != "[]float64" {
+		t.Errorf("Vec is a %s underneath", typ)
+	}
+	if types.IsNumeric(vec) || !types.IsNumeric(types.TypeOf(lastUse(f, "h"))) {
+		t.Errorf("IsNumeric is wrong")
+	}
+	if types.Method(vec, "Len") == nil || types.Method(vec, "_dot_add") == nil || types.Method(vec, "_dot_sub") != nil {
+		t.Errorf("Method found the wrong methods of Vec")
+	}
+	additive := types.TypeOf(lastUse(f, "sum"))
+	if m := types.MethodType(additive, "_dot_add"); m == nil || typeString(t, m) != "func(Additive) Additive" {
+		t.Errorf("MethodType found the wrong method of Additive")
+	}
+}